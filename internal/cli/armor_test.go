@@ -0,0 +1,67 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecryptCmd_Armor(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	outAndErr := &bytes.Buffer{}
+
+	execute := func(args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetIn(strings.NewReader("hello\n"))
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, execute("encrypt", "-p", "3", "-t", "2", "-i", "", "--armor"), outAndErr)
+
+	encrypted, err := os.ReadFile("out/secret.enc")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(encrypted), "-----BEGIN STEGO SECRET-----\nFilename: secret\n"))
+	assert.True(t, strings.HasSuffix(string(encrypted), "-----END STEGO SECRET-----\n"))
+
+	key, err := os.ReadFile("out/001.key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(key), "-----BEGIN STEGO PARTIAL KEY-----\n"))
+
+	// the armored files can be indented, i.e. pasted in a YAML file
+	indented := "  " + strings.ReplaceAll(string(key), "\n", "\n  ")
+	require.NoError(t, os.WriteFile("out/001.key", []byte(indented), 0o600))
+
+	require.NoError(t, execute("inspect", "out/secret.enc", "out/001.key"), outAndErr)
+	assert.Contains(t, outAndErr.String(), "Threshold:      2")
+
+	require.NoError(t, execute("verify", "out"), outAndErr)
+
+	require.NoError(t, execute("decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--key", "out/002.key"), outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+
+	// the share set of the armored file is updated, keeping it armored
+	err = execute("reshare", "--key", "out/001.key", "--key", "out/003.key", "-p", "2", "-t", "2",
+		"-o", "out/new", "-f", "out/secret.enc", "-i", "")
+	require.NoError(t, err, outAndErr)
+
+	encrypted, err = os.ReadFile("out/secret.enc")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(encrypted), "-----BEGIN STEGO SECRET-----\n"))
+
+	require.NoError(t, os.Remove("out/secret"))
+	require.NoError(t, execute("decrypt", "-f", "out/secret.enc", "--key", "out/new/001.key", "--key", "out/new/002.key"), outAndErr)
+}
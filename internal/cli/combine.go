@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newCombineCmd() *cobra.Command {
+	combineCmd := &cobra.Command{
+		Use:   "combine",
+		Short: "Recover a secret from the provided keys or images, printing it to STDOUT",
+		RunE:  runCombineCmd,
+	}
+
+	combineCmd.Flags().StringArrayVar(&keyFiles, "key", []string{}, "The files containing the partial keys")
+	combineCmd.Flags().StringArrayVar(&imageFiles, "img", []string{}, "The image files containing the hidden partial keys")
+	combineCmd.Flags().StringArrayVar(&qrFiles, "qr", []string{}, "The QR code images of the partial keys")
+	combineCmd.Flags().BoolVar(&usePIN, "pin", false, "Ask the PIN of every image, for images protected by a PIN (see --stego lsb-scatter)")
+
+	return combineCmd
+}
+
+func runCombineCmd(cmd *cobra.Command, _ []string) error {
+	decrypter, err := buildDecrypter(cmd)
+	if err != nil {
+		return errors.Wrap(err, "failed building decrypter")
+	}
+
+	// STDOUT is reserved to the secret
+	decrypter.Logger = log.NewSimpleLogger(cmd.ErrOrStderr(), log.NewLevel(silent, verbose))
+
+	secret, err := decrypter.Combine()
+	if err != nil {
+		return errors.Wrap(err, "failed recovering secret")
+	}
+
+	_, err = cmd.OutOrStdout().Write(secret)
+
+	return errors.Wrap(err, "failed writing secret")
+}
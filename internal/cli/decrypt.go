@@ -1,6 +1,9 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/enrichman/stegosecrets/internal/decrypt"
 	"github.com/enrichman/stegosecrets/internal/log"
 	"github.com/pkg/errors"
@@ -9,9 +12,19 @@ import (
 
 var (
 	encryptedFile string
+	decryptedFile string
+	extractDir    string
 	masterKeyFile string
 	keyFiles      []string
 	imageFiles    []string
+	qrFiles       []string
+	legacy        bool
+
+	checksumFile          string
+	encryptedChecksumFile string
+	force                 bool
+
+	usePIN bool
 )
 
 func newDecryptCmd() *cobra.Command {
@@ -21,11 +34,29 @@ func newDecryptCmd() *cobra.Command {
 		RunE:  runDecryptCmd,
 	}
 
-	decryptCmd.Flags().StringVarP(&encryptedFile, "file", "f", "", "The file to decrypt")
+	decryptCmd.Flags().StringVarP(&encryptedFile, "file", "f", "", `The file to decrypt, "-" to read it from STDIN`)
+	decryptCmd.Flags().StringVarP(&decryptedFile, "output", "o", "", `The file where the decrypted content will be saved, "-" for STDOUT.
+If not specified the original name stored in the encrypted file is used (STDOUT if reading from STDIN)`)
+	decryptCmd.Flags().StringVar(&extractDir, "extract", "",
+		`Extract the decrypted directory (see --dir of encrypt) into the directory, instead of saving its archive`)
 	decryptCmd.Flags().StringVar(&masterKeyFile, "master-key", "", `The master-key used to decrypt the file.
 If provided keys or images will be ignored`)
 	decryptCmd.Flags().StringArrayVar(&keyFiles, "key", []string{}, "The files containing the partial keys")
 	decryptCmd.Flags().StringArrayVar(&imageFiles, "img", []string{}, "The image files containing the hidden partial keys")
+	decryptCmd.Flags().StringArrayVar(&qrFiles, "qr", []string{}, "The QR code images of the partial keys (see --qr-code of encrypt)")
+	decryptCmd.Flags().BoolVar(&usePIN, "pin", false, "Ask the PIN of every image, for images protected by a PIN (see --stego lsb-scatter)")
+	decryptCmd.Flags().BoolVar(&legacy, "legacy", false, `Decrypt a file created by an older release (unauthenticated AES-CFB).
+A wrong key will not be detected.`)
+	decryptCmd.Flags().StringVar(&checksumFile, "checksum", "", `The checksum file of the decrypted file.
+If not specified the <file>.checksum file next to the encrypted file will be used, if found.`)
+	decryptCmd.Flags().StringVar(&encryptedChecksumFile, "enc-checksum", "", `The checksum file of the encrypted file.
+If not specified the <file>.enc.checksum file next to the encrypted file will be used, if found.`)
+	decryptCmd.Flags().BoolVar(&usePassphrase, "passphrase", false,
+		`Derive the key from a passphrase, for files encrypted with --passphrase.
+The passphrase will be asked interactively.`)
+	decryptCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "",
+		`Derive the key from the passphrase read from the first line of the file.`)
+	decryptCmd.Flags().BoolVar(&force, "force", false, "Write the decrypted file even if the checksum verification fails")
 
 	return decryptCmd
 }
@@ -35,15 +66,54 @@ func runDecryptCmd(cmd *cobra.Command, _ []string) error {
 		return errors.New("missing file to decrypt. Use -f/--file flag")
 	}
 
-	decrypter, err := buildDecrypter()
+	decrypterOpts := []decrypt.OptFunc{}
+
+	if extractDir != "" && (encryptedFile == "-" || decryptedFile != "") {
+		return errors.New("--extract cannot be used with --output or reading from STDIN")
+	}
+
+	if extractDir != "" {
+		decrypterOpts = append(decrypterOpts, decrypt.WithExtractDir(extractDir))
+	}
+
+	passphrase, err := getPassphrase(cmd, false)
+	if err != nil {
+		return err
+	}
+
+	if passphrase != nil {
+		decrypterOpts = append(decrypterOpts, decrypt.WithPassphrase(passphrase))
+	}
+
+	decrypter, err := buildDecrypter(cmd, decrypterOpts...)
 	if err != nil {
 		return errors.Wrap(err, "failed building decrypter")
 	}
 
+	toStdout := decryptedFile == "-" || (encryptedFile == "-" && decryptedFile == "")
+
+	// the logs must not be mixed with the decrypted content
+	logOutput := cmd.OutOrStdout()
+	if toStdout {
+		logOutput = cmd.ErrOrStderr()
+	}
+
 	loggerLevel := log.NewLevel(silent, verbose)
-	decrypter.Logger = log.NewSimpleLogger(cmd.OutOrStdout(), loggerLevel)
+	decrypter.Logger = log.NewSimpleLogger(logOutput, loggerLevel)
+
+	switch {
+	case encryptedFile == "-":
+		err = decryptFromStdin(cmd, decrypter, toStdout)
+	case toStdout:
+		err = decrypter.DecryptTo(encryptedFile, cmd.OutOrStdout())
+	default:
+		if decryptedFile != "" {
+			decrypter.OutputFile = decryptedFile
+		}
+
+		err = decrypter.Decrypt(encryptedFile)
+	}
 
-	err = decrypter.Decrypt(encryptedFile)
 	if err != nil {
 		return errors.Wrapf(err, "failed decrypting file '%s'", encryptedFile)
 	}
@@ -51,8 +121,55 @@ func runDecryptCmd(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
-func buildDecrypter() (*decrypt.Decrypter, error) {
-	decrypterOpts := []decrypt.OptFunc{}
+// decryptFromStdin decrypts the content read from STDIN, writing it to STDOUT or to the output file.
+func decryptFromStdin(cmd *cobra.Command, decrypter *decrypt.Decrypter, toStdout bool) error {
+	if toStdout {
+		return decrypter.DecryptStream(stdinReader(cmd), cmd.OutOrStdout())
+	}
+
+	out, err := os.Create(decryptedFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed creating file '%s'", decryptedFile)
+	}
+
+	err = decrypter.DecryptStream(stdinReader(cmd), out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		// the content written before the failure is not authenticated
+		_ = os.Remove(decryptedFile)
+
+		return err
+	}
+
+	decrypter.Logger.Print("Decrypted file saved to:", decryptedFile)
+
+	return nil
+}
+
+func buildDecrypter(cmd *cobra.Command, decrypterOpts ...decrypt.OptFunc) (*decrypt.Decrypter, error) {
+	// the passphrase of the holders is asked only for the protected parts, as they are read
+	decrypterOpts = append(decrypterOpts, decrypt.WithPartPassphraseFunc(func(filename string) ([]byte, error) {
+		return readPassword(cmd, fmt.Sprintf("Enter passphrase of the holder of partial key '%s': ", filename))
+	}))
+
+	if legacy {
+		decrypterOpts = append(decrypterOpts, decrypt.WithLegacyCipher())
+	}
+
+	if checksumFile != "" {
+		decrypterOpts = append(decrypterOpts, decrypt.WithChecksumFile(checksumFile))
+	}
+
+	if encryptedChecksumFile != "" {
+		decrypterOpts = append(decrypterOpts, decrypt.WithEncryptedChecksumFile(encryptedChecksumFile))
+	}
+
+	if force {
+		decrypterOpts = append(decrypterOpts, decrypt.WithForce())
+	}
 
 	if masterKeyFile != "" {
 		decrypterOpts = append(decrypterOpts, decrypt.WithMasterKeyFile(masterKeyFile))
@@ -62,8 +179,23 @@ func buildDecrypter() (*decrypt.Decrypter, error) {
 		decrypterOpts = append(decrypterOpts, decrypt.WithPartialKeyFile(filename))
 	}
 
+	for _, filename := range qrFiles {
+		decrypterOpts = append(decrypterOpts, decrypt.WithPartialKeyQRFile(filename))
+	}
+
 	for _, filename := range imageFiles {
-		decrypterOpts = append(decrypterOpts, decrypt.WithPartialKeyImageFile(filename))
+		if !usePIN {
+			decrypterOpts = append(decrypterOpts, decrypt.WithPartialKeyImageFile(filename))
+
+			continue
+		}
+
+		pin, err := readPassword(cmd, fmt.Sprintf("Enter PIN for image '%s': ", filename))
+		if err != nil {
+			return nil, err
+		}
+
+		decrypterOpts = append(decrypterOpts, decrypt.WithPartialKeyImageFilePIN(filename, pin))
 	}
 
 	decrypter, err := decrypt.NewDecrypter(decrypterOpts...)
@@ -2,10 +2,14 @@ package cli_test
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/enrichman/stegosecrets/internal/cli"
 	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/stego"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,6 +27,21 @@ func TestDecryptCmd_NoInput(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDecryptCmd_WithoutLegacyCipher(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	rootCmd.SetArgs([]string{
+		"decrypt",
+		"-f", testAssetsDir + "secret.enc",
+		"--master-key", testAssetsDir + "secret.enc.key",
+	})
+
+	err := rootCmd.Execute()
+	require.ErrorIs(t, err, stego.ErrMissingHeader)
+}
+
 func TestDecryptCmd(t *testing.T) {
 	tt := []struct {
 		name           string
@@ -74,6 +93,7 @@ func TestDecryptCmd(t *testing.T) {
 			cmdArgs := append([]string{
 				"decrypt",
 				"-f", testAssetsDir + "secret.enc",
+				"--legacy",
 			}, tc.args...)
 
 			rootCmd.SetArgs(cmdArgs)
@@ -94,3 +114,119 @@ func TestDecryptCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestDecryptCmd_ChecksumMismatch(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	err = os.WriteFile("out/secret.checksum", []byte("0000\tsecret"), 0o600)
+	require.NoError(t, err)
+
+	decryptArgs := []string{"decrypt", "-f", "out/secret.enc", "--master-key", "out/secret.enc.key"}
+
+	rootCmd.SetArgs(decryptArgs)
+	err = rootCmd.Execute()
+	require.ErrorIs(t, err, file.ErrChecksumMismatch)
+	assert.NoFileExists(t, "out/secret")
+
+	rootCmd.SetArgs(append(decryptArgs, "--force"))
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "out/secret")
+}
+
+func TestDecryptCmd_OutputChecksumMismatch(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	outAndErr := &bytes.Buffer{}
+
+	execute := func(args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetIn(strings.NewReader("hello\n"))
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, execute("encrypt"), outAndErr)
+
+	err := os.WriteFile("out/secret.checksum", []byte("0000\tsecret"), 0o600)
+	require.NoError(t, err)
+
+	// the checksum next to the encrypted file is verified, wherever the content is saved
+	err = execute("decrypt", "-f", "out/secret.enc", "--master-key", "out/secret.enc.key", "-o", "out/other")
+	require.ErrorIs(t, err, file.ErrChecksumMismatch)
+	assert.NoFileExists(t, "out/other")
+
+	err = execute("decrypt", "-f", "out/secret.enc", "--master-key", "out/secret.enc.key", "-o", "-")
+	require.ErrorIs(t, err, file.ErrChecksumMismatch)
+}
+
+func TestDecryptCmd_OutputOtherDirectory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	// the directory of the encrypted file is not writable, and the output can be on another filesystem
+	require.NoError(t, os.Chmod("out", 0o500))
+	defer func() { _ = os.Chmod("out", 0o755) }()
+
+	output := filepath.Join(t.TempDir(), "decrypted.txt")
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--master-key", "out/secret.enc.key", "-o", output})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(content))
+
+	leftovers, err := filepath.Glob(filepath.Join("out", ".stego-*"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestDecryptCmd_EncryptedChecksumMismatch(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	rootCmd.SetArgs([]string{
+		"decrypt",
+		"-f", testAssetsDir + "secret.enc",
+		"--master-key", testAssetsDir + "secret.enc.key",
+		"--enc-checksum", testAssetsDir + "001.jpg.checksum",
+		"--legacy",
+	})
+
+	err := rootCmd.Execute()
+	require.ErrorIs(t, err, file.ErrChecksumMismatch)
+}
@@ -2,21 +2,38 @@ package cli
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 
 	"github.com/enrichman/stegosecrets/internal/encrypt"
 	"github.com/enrichman/stegosecrets/internal/log"
-	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/image"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 var (
 	cleartextFile string
+	cleartextDir  string
+	compressDir   bool
 	keyParts      uint8
 	keyThreshold  uint8
 	outputDir     string
 	imagesDir     string
+	keepMasterKey bool
+
+	usePassphrase  bool
+	passphraseFile string
+
+	stegoBackend      string
+	holderPassphrases bool
+	verifiable        bool
+	keyFormat         string
+	qrCodes           bool
+	qrCodesSVG        bool
+	armored           bool
 )
 
 func newEncryptCmd() *cobra.Command {
@@ -27,7 +44,12 @@ func newEncryptCmd() *cobra.Command {
 	}
 
 	encryptCmd.Flags().StringVarP(&cleartextFile, "file", "f", "",
-		`The file to encrypt. If not specified a message from STDIN will be read.`)
+		`The file to encrypt, "-" to read the whole content from STDIN (i.e. from a pipe).
+If not specified a message from STDIN will be read.`)
+	encryptCmd.Flags().StringVar(&cleartextDir, "dir", "",
+		`The directory to encrypt. It is stored as a tar archive, keeping the modes and the modification times.
+Extract it with the --extract flag of decrypt.`)
+	encryptCmd.Flags().BoolVar(&compressDir, "compress", false, `Compress the archive of the directory with gzip (see --dir).`)
 	encryptCmd.Flags().Uint8VarP(&keyParts, "parts", "p", 0,
 		`The number of parts (partial keys) in which the secret will be splitted.
 If empty only the master-key will be generated.`)
@@ -38,6 +60,33 @@ If empty only the master-key will be generated.`)
 	encryptCmd.Flags().StringVarP(&imagesDir, "images", "i", "images",
 		`The directory where to look for the images where the partial keys will be hidden.
 If empty no images will be generated.`)
+	encryptCmd.Flags().BoolVar(&holderPassphrases, "holder-passphrase", false,
+		`Encrypt every partial key with a passphrase of its holder, asked for every part.
+The passphrase will be asked when the partial key is used.`)
+	encryptCmd.Flags().StringVar(&stegoBackend, "stego", image.DefaultBackend,
+		fmt.Sprintf("The steganography backend used to hide the partial keys into the images %v", image.Backends()))
+	encryptCmd.Flags().BoolVar(&verifiable, "verifiable", false,
+		`Split the key into verifiable parts: every holder can check its part against the commitments
+saved along with the encrypted file (see verify-share).`)
+	encryptCmd.Flags().StringVar(&keyFormat, "format", encrypt.KeyFormatBase64,
+		fmt.Sprintf("The format of the partial key files [%s %s].\nThe mnemonic is a list of words, easier to write on paper.",
+			encrypt.KeyFormatBase64, encrypt.KeyFormatMnemonic))
+	encryptCmd.Flags().BoolVar(&qrCodes, "qr-code", false,
+		`Write every partial key also as a QR code image (NNN.qr.png), for paper backups.`)
+	encryptCmd.Flags().BoolVar(&qrCodesSVG, "qr-svg", false,
+		`Write the QR codes also as SVG images (NNN.qr.svg). It implies --qr-code.`)
+	encryptCmd.Flags().BoolVar(&armored, "armor", false,
+		`Write the encrypted file and the partial keys as ASCII armored text (-----BEGIN STEGO SECRET-----),
+to paste them in emails, tickets or configuration files.`)
+	encryptCmd.Flags().BoolVar(&keepMasterKey, "keep-master-key", false,
+		`Save the master-key also when it is split into parts.
+Anyone having the master-key can decrypt the secret, bypassing the threshold.`)
+
+	encryptCmd.Flags().BoolVar(&usePassphrase, "passphrase", false,
+		`Derive the master-key from a passphrase (Argon2id) instead of generating a random one.
+The passphrase will be asked interactively. The key can still be split into parts.`)
+	encryptCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "",
+		`Derive the master-key from the passphrase read from the first line of the file.`)
 
 	return encryptCmd
 }
@@ -47,39 +96,166 @@ func runEncryptCmd(cmd *cobra.Command, _ []string) error {
 		return errors.Errorf("threshold %d cannot exceed the parts %d", keyThreshold, keyParts)
 	}
 
-	var (
-		toEncrypt []byte
-		err       error
-	)
+	if cleartextDir != "" && cleartextFile != "" {
+		return errors.New("only one of --file and --dir can be specified")
+	}
 
-	if cleartextFile != "" {
-		toEncrypt, err = file.ReadFile(cleartextFile)
-		cleartextFile = filepath.Base(cleartextFile)
-	} else {
-		toEncrypt, err = getInputFromStdin(cmd)
-		cleartextFile = "secret"
+	if compressDir && cleartextDir == "" {
+		return errors.New("--compress can be used only with --dir")
 	}
 
-	if err != nil {
-		return errors.Wrapf(err, "failed getting input to encrypt '%s'", cleartextFile)
+	var toEncrypt io.Reader
+
+	// the name of the encrypted file
+	name := "secret"
+
+	switch {
+	case cleartextDir != "":
+		// the directory is archived while it is encrypted
+		name = cleartextDir
+	case cleartextFile == "-":
+		// the content is streamed, without prompting
+		toEncrypt = stdinReader(cmd)
+	case cleartextFile == "":
+		input, err := getInputFromStdin(cmd)
+		if err != nil {
+			return errors.Wrap(err, "failed getting input to encrypt from stdin")
+		}
+
+		toEncrypt = bytes.NewReader(input)
+	default:
+		f, err := os.Open(cleartextFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed opening file to encrypt '%s'", cleartextFile)
+		}
+		defer f.Close()
+
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			return errors.Errorf("'%s' is a directory, use the --dir flag to encrypt it", cleartextFile)
+		}
+
+		toEncrypt = f
+		name = filepath.Base(cleartextFile)
+	}
+
+	if keepMasterKey && keyParts > 1 {
+		fmt.Fprintln(cmd.ErrOrStderr(),
+			"⚠️  WARNING: --keep-master-key is set, the master-key will be saved along with the partial keys.\n"+
+				"⚠️  Anyone having it can decrypt the secret without reaching the threshold: store it safely or delete it!")
 	}
 
 	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
 
-	encrypter, err := encrypt.NewEncrypter(
+	encrypterOpts := []encrypt.OptFunc{
 		encrypt.WithPartsAndThreshold(keyParts, keyThreshold),
 		encrypt.WithOutputDir(outputDir),
 		encrypt.WithImagesDir(imagesDir),
+		encrypt.WithSteganographer(stegoBackend),
+		encrypt.WithKeepMasterKey(keepMasterKey),
+		encrypt.WithVerifiable(verifiable),
+		encrypt.WithKeyFormat(keyFormat),
+		encrypt.WithQRCodes(qrCodes, qrCodesSVG),
+		encrypt.WithArmor(armored),
 		encrypt.WithLogger(logger),
-	)
+	}
+
+	passphrase, err := getPassphrase(cmd, true)
+	if err != nil {
+		return err
+	}
+
+	if passphrase != nil {
+		encrypterOpts = append(encrypterOpts, encrypt.WithPassphrase(passphrase))
+	}
+
+	pins, err := getImagePINs(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	encrypterOpts = append(encrypterOpts, encrypt.WithImagePINs(pins))
+
+	partPassphrases, err := getPartPassphrases(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	encrypterOpts = append(encrypterOpts, encrypt.WithPartPassphrases(partPassphrases))
+
+	encrypter, err := encrypt.NewEncrypter(encrypterOpts...)
 	if err != nil {
 		return errors.Wrap(err, "failed creating encrypter")
 	}
 
-	err = encrypter.Encrypt(bytes.NewReader(toEncrypt), cleartextFile)
+	if cleartextDir != "" {
+		err = encrypter.EncryptDir(cleartextDir, compressDir)
+	} else {
+		err = encrypter.Encrypt(toEncrypt, name)
+	}
+
 	if err != nil {
-		return errors.Wrapf(err, "failed encrypting file '%s'", cleartextFile)
+		return errors.Wrapf(err, "failed encrypting file '%s'", name)
 	}
 
 	return nil
 }
+
+// getPassphrase returns the passphrase read from the passphrase file or asked interactively,
+// or nil if the passphrase mode is not enabled.
+func getPassphrase(cmd *cobra.Command, confirm bool) ([]byte, error) {
+	switch {
+	case passphraseFile != "":
+		return readPassphraseFile(passphraseFile)
+	case !usePassphrase:
+		return nil, nil
+	case confirm:
+		return readNewPassword(cmd, "Enter passphrase: ")
+	default:
+		return readPassword(cmd, "Enter passphrase: ")
+	}
+}
+
+// getImagePINs asks the PIN of the holder of every image, if needed by the steganography backend.
+func getImagePINs(cmd *cobra.Command, parts uint8) ([][]byte, error) {
+	backend, err := image.Backend(stegoBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, keyed := backend.(image.KeyedSteganographer); !keyed || parts < 2 || imagesDir == "" {
+		return nil, nil
+	}
+
+	pins := make([][]byte, 0, parts)
+
+	for i := 1; i <= int(parts); i++ {
+		pin, err := readNewPassword(cmd, fmt.Sprintf("Enter PIN for partial key %03d: ", i))
+		if err != nil {
+			return nil, err
+		}
+
+		pins = append(pins, pin)
+	}
+
+	return pins, nil
+}
+
+// getPartPassphrases asks the passphrase of the holder of every part, if enabled.
+func getPartPassphrases(cmd *cobra.Command, parts uint8) ([][]byte, error) {
+	if !holderPassphrases || parts < 2 {
+		return nil, nil
+	}
+
+	passphrases := make([][]byte, 0, parts)
+
+	for i := 1; i <= int(parts); i++ {
+		passphrase, err := readNewPassword(cmd, fmt.Sprintf("Enter passphrase of the holder of partial key %03d: ", i))
+		if err != nil {
+			return nil, err
+		}
+
+		passphrases = append(passphrases, passphrase)
+	}
+
+	return passphrases, nil
+}
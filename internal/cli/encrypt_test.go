@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/enrichman/stegosecrets/internal/cli"
 	"github.com/stretchr/testify/assert"
@@ -63,3 +64,393 @@ func TestEncryptCmd_Stdin(t *testing.T) {
 	assert.FileExists(t, "out/secret.checksum")
 	assert.FileExists(t, "out/secret.enc.checksum")
 }
+
+func TestEncryptCmd_StdinVerbose(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+
+	rootCmd.SetIn(strings.NewReader("my-secret-message\n"))
+
+	rootCmd.SetArgs([]string{"encrypt", "--verbose"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	// the secret is never logged
+	assert.Contains(t, outAndErr.String(), "Generated master-key")
+	assert.NotContains(t, outAndErr.String(), "my-secret-message")
+}
+
+func TestEncryptDecryptCmd(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--master-key", "out/secret.enc.key"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+}
+
+func TestEncryptDecryptCmd_Passphrase(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\nmy passphrase\nmy passphrase\n"))
+	rootCmd.SetArgs([]string{"encrypt", "--passphrase", "-p", "3", "-t", "2", "-i", ""})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.NoFileExists(t, "out/secret.enc.key")
+
+	// wrong passphrase
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("wrong passphrase\n"))
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--passphrase"})
+
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.NoFileExists(t, "out/secret")
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("my passphrase\n"))
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--passphrase"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+
+	// the same key can be recovered from the parts
+	require.NoError(t, os.Remove("out/secret"))
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--key", "out/003.key"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err = os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+}
+
+func TestEncryptDecryptCmd_ImagePINs(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n1111\n1111\n2222\n2222\n3333\n3333\n"))
+	rootCmd.SetArgs([]string{
+		"encrypt", "-p", "3", "-t", "2", "-i", "../../test/assets/p5t3", "--stego", "lsb-scatter",
+	})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	// the PINs are needed to read the partial keys
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--img", "out/001.png", "--img", "out/003.png"})
+
+	err = rootCmd.Execute()
+	require.Error(t, err)
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("1111\n3333\n"))
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--img", "out/001.png", "--img", "out/003.png", "--pin"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+}
+
+func TestEncryptDecryptCmd_HolderPassphrases(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\nalice\nalice\nbob\nbob\ncarol\ncarol\n"))
+	rootCmd.SetArgs([]string{
+		"encrypt", "-p", "3", "-t", "2", "-i", "../../test/assets/p5t3", "--holder-passphrase",
+	})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("alice\nwrong\n"))
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--img", "out/003.png"})
+
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wrong passphrase")
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("alice\ncarol\n"))
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--img", "out/003.png"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+}
+
+func TestEncryptDecryptCmd_MnemonicFormat(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt", "-p", "3", "-t", "2", "-i", "", "--format", "mnemonic"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	content, err := os.ReadFile("out/001.key")
+	require.NoError(t, err)
+	assert.Regexp(t, `^[a-z]+( [a-z]+)*\n`, string(content))
+
+	// the words can be abbreviated to their first four letters
+	words := strings.Fields(string(content))
+	for i, word := range words {
+		if len(word) > 4 {
+			words[i] = word[:4]
+		}
+	}
+
+	require.NoError(t, os.WriteFile("out/002.key", []byte(strings.Join(words, " ")), 0o600))
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--key", "out/002.key", "--key", "out/003.key"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt", "-p", "3", "-t", "2", "-i", "", "--format", "hex"})
+
+	err = rootCmd.Execute()
+	require.Error(t, err)
+}
+
+func TestEncryptDecryptCmd_QRCodes(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt", "-p", "3", "-t", "2", "-i", testAssetsDir, "--qr-svg"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "out/001.png")
+	assert.FileExists(t, "out/001.qr.png")
+	assert.FileExists(t, "out/001.qr.svg")
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--qr", "out/001.qr.png", "--key", "out/002.key"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello\n"), decrypted)
+
+	// the QR codes are verified along with the keys and the images
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"verify", "out"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+}
+
+func TestEncryptDecryptCmd_Pipe(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	// a binary content, not ending with a new line
+	content := []byte{0x00, 0xff, '\n', 0x1b, 'd', 'u', 'm', 'p', 0x00}
+
+	execute := func(stdin []byte, args ...string) (*bytes.Buffer, *bytes.Buffer, error) {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(out)
+		rootCmd.SetErr(errOut)
+		rootCmd.SetIn(bytes.NewReader(stdin))
+		rootCmd.SetArgs(args)
+
+		return out, errOut, rootCmd.Execute()
+	}
+
+	out, _, err := execute(content, "encrypt", "-f", "-", "-p", "3", "-t", "2", "-i", "")
+	require.NoError(t, err, out)
+	assert.NotContains(t, out.String(), "Enter")
+
+	// the decrypted content is written to STDOUT, the logs to STDERR
+	out, errOut, err := execute(nil, "decrypt", "-f", "out/secret.enc", "-o", "-", "--key", "out/001.key", "--key", "out/002.key")
+	require.NoError(t, err, errOut)
+	assert.Equal(t, content, out.Bytes())
+	assert.Contains(t, errOut.String(), "Decrypting")
+	assert.NoFileExists(t, "out/secret")
+
+	encrypted, err := os.ReadFile("out/secret.enc")
+	require.NoError(t, err)
+
+	// reading from STDIN the decrypted content is written to STDOUT, if not specified
+	out, errOut, err = execute(encrypted, "decrypt", "-f", "-", "--key", "out/001.key", "--key", "out/003.key")
+	require.NoError(t, err, errOut)
+	assert.Equal(t, content, out.Bytes())
+
+	out, errOut, err = execute(encrypted, "decrypt", "-f", "-", "-o", "out/dump", "--key", "out/002.key", "--key", "out/003.key")
+	require.NoError(t, err, errOut)
+	assert.Contains(t, out.String(), "Decrypted file saved to: out/dump")
+
+	decrypted, err := os.ReadFile("out/dump")
+	require.NoError(t, err)
+	assert.Equal(t, content, decrypted)
+
+	// a truncated content does not leave a partial file
+	_, _, err = execute(encrypted[:len(encrypted)-1], "decrypt", "-f", "-", "-o", "out/truncated", "--key", "out/002.key", "--key", "out/003.key")
+	require.Error(t, err)
+	assert.NoFileExists(t, "out/truncated")
+}
+
+func TestEncryptDecryptCmd_Dir(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	mtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	require.NoError(t, os.MkdirAll("out/mydir/sub", 0o755))
+	require.NoError(t, os.WriteFile("out/mydir/a.txt", []byte("hello"), 0o600))
+	require.NoError(t, os.WriteFile("out/mydir/sub/run.sh", []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Chtimes("out/mydir/a.txt", mtime, mtime))
+
+	outAndErr := &bytes.Buffer{}
+
+	execute := func(args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	// a directory needs the --dir flag
+	require.Error(t, execute("encrypt", "-f", "out/mydir", "-o", "out/enc"))
+
+	err := execute("encrypt", "--dir", "out/mydir", "--compress", "-o", "out/enc", "-p", "3", "-t", "2", "-i", "")
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "out/enc/mydir.tar.gz.enc")
+	assert.FileExists(t, "out/enc/mydir.tar.gz.checksum")
+
+	require.NoError(t, execute("inspect", "out/enc/mydir.tar.gz.enc"), outAndErr)
+	assert.Contains(t, outAndErr.String(), "directory (tar.gz archive)")
+
+	err = execute("decrypt", "-f", "out/enc/mydir.tar.gz.enc", "--extract", "out/restored",
+		"--key", "out/enc/001.key", "--key", "out/enc/002.key")
+	require.NoError(t, err, outAndErr)
+	assert.NoFileExists(t, "out/enc/mydir.tar.gz")
+
+	content, err := os.ReadFile("out/restored/mydir/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), content)
+
+	info, err := os.Stat("out/restored/mydir/a.txt")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+
+	info, err = os.Stat("out/restored/mydir/sub/run.sh")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	// without --extract the archive is saved
+	err = execute("decrypt", "-f", "out/enc/mydir.tar.gz.enc", "--key", "out/enc/001.key", "--key", "out/enc/003.key")
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "out/enc/mydir.tar.gz")
+
+	// a file is not an archive
+	require.NoError(t, execute("encrypt", "-f", "out/mydir/a.txt", "-o", "out/file"), outAndErr)
+
+	err = execute("decrypt", "-f", "out/file/a.txt.enc", "--master-key", "out/file/a.txt.enc.key", "--extract", "out/notadir")
+	require.Error(t, err)
+	assert.NoFileExists(t, "out/file/a.txt")
+}
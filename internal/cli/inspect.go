@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/enrichman/stegosecrets/internal/inspect"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var jsonOutput bool
+
+func newInspectCmd() *cobra.Command {
+	inspectCmd := &cobra.Command{
+		Use:   "inspect FILE...",
+		Short: "Show the info of partial keys, images and encrypted files without decrypting them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runInspectCmd,
+	}
+
+	inspectCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the info as JSON")
+
+	return inspectCmd
+}
+
+func runInspectCmd(cmd *cobra.Command, args []string) error {
+	reports := make([]*inspect.Report, 0, len(args))
+
+	for _, filename := range args {
+		report, err := inspect.File(filename)
+		if err != nil {
+			return errors.Wrapf(err, "failed inspecting file '%s'", filename)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+
+		return errors.Wrap(encoder.Encode(reports), "failed encoding JSON output")
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+
+		fmt.Fprint(cmd.OutOrStdout(), report)
+	}
+
+	return nil
+}
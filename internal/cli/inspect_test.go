@@ -0,0 +1,44 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/enrichman/stegosecrets/internal/inspect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectCmd_JSON(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	rootCmd.SetArgs([]string{"inspect", "--json", testAssetsDir + "001.key", testAssetsDir + "002.jpg"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	reports := []inspect.Report{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &reports))
+	require.Len(t, reports, 2)
+
+	assert.Equal(t, inspect.TypeKey, reports[0].Type)
+	assert.Equal(t, inspect.TypeImage, reports[1].Type)
+	assert.Equal(t, uint8(3), reports[1].Part.Threshold)
+}
+
+func TestInspectCmd_NoInput(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	rootCmd.SetArgs([]string{"inspect"})
+
+	err := rootCmd.Execute()
+	assert.Error(t, err)
+}
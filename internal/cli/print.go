@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/internal/sheet"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var sheetsDir string
+
+func newPrintCmd() *cobra.Command {
+	printCmd := &cobra.Command{
+		Use:   "print DIR|FILE.key...",
+		Short: "Render the partial keys into printable sheets, for paper backups",
+		Long: `Render the partial keys into printable sheets, for paper backups.
+Every sheet contains the partial key as mnemonic, base64 and QR code, its info and the recovery instructions.
+The sheets are HTML pages generated offline: print them from the browser (or save them as PDF).
+If a directory is provided all its partial keys are printed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runPrintCmd,
+	}
+
+	printCmd.Flags().StringVarP(&sheetsDir, "output", "o", "",
+		`The output directory where the sheets will be saved. If not specified they are saved next to the keys.`)
+	printCmd.Flags().StringVarP(&encryptedFile, "file", "f", "",
+		`The encrypted file of the partial keys, to print its name.
+If not specified the encrypted file next to the keys will be used, if found.`)
+
+	return printCmd
+}
+
+func runPrintCmd(cmd *cobra.Command, args []string) error {
+	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
+
+	keys, err := partialKeyFiles(args)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no partial keys found")
+	}
+
+	if sheetsDir != "" {
+		if err := os.MkdirAll(sheetsDir, 0o744); err != nil {
+			return errors.Wrapf(err, "failed creating output directory '%s'", sheetsDir)
+		}
+	}
+
+	for _, keyFile := range keys {
+		enc := encryptedFile
+		if enc == "" {
+			enc = findEncryptedFile(filepath.Dir(keyFile))
+		}
+
+		s, err := sheet.NewFromKeyFile(keyFile, enc)
+		if err != nil {
+			return errors.Wrapf(err, "failed creating sheet of '%s'", keyFile)
+		}
+
+		content := &bytes.Buffer{}
+		if err := s.Write(content); err != nil {
+			return err
+		}
+
+		dir := sheetsDir
+		if dir == "" {
+			dir = filepath.Dir(keyFile)
+		}
+
+		sheetFile := filepath.Join(dir, strings.TrimSuffix(filepath.Base(keyFile), ".key")+sheet.Extension)
+		if err := file.WriteFile(logger, content.Bytes(), sheetFile); err != nil {
+			return err
+		}
+
+		logger.Print(fmt.Sprintf("🖨️  Sheet of partial key '%s' saved in '%s'", keyFile, sheetFile))
+	}
+
+	return nil
+}
+
+// partialKeyFiles returns the partial key files, looking for them in the directories.
+func partialKeyFiles(args []string) ([]string, error) {
+	keys := []string{}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading '%s'", arg)
+		}
+
+		if !info.IsDir() {
+			keys = append(keys, arg)
+
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(arg, "*.key"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed looking for partial keys in '%s'", arg)
+		}
+
+		for _, match := range matches {
+			// the master key is not a partial key
+			if !strings.HasSuffix(match, ".enc.key") {
+				keys = append(keys, match)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// findEncryptedFile returns the encrypted file in the directory, if there is only one.
+func findEncryptedFile(dir string) string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.enc"))
+	if err != nil || len(matches) != 1 {
+		return ""
+	}
+
+	return matches[0]
+}
@@ -0,0 +1,71 @@
+package cli_test
+
+import (
+	"bytes"
+	"html"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/mnemonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintCmd(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt", "-p", "3", "-t", "2", "-i", ""})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"print", "out"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.NoFileExists(t, "out/secret.enc.sheet.html")
+
+	sheet, err := os.ReadFile("out/002.sheet.html")
+	require.NoError(t, err)
+
+	encodedKey, err := os.ReadFile("out/002.key")
+	require.NoError(t, err)
+
+	key, err := file.ReadKey("out/002.key")
+	require.NoError(t, err)
+
+	words, err := mnemonic.Encode(key)
+	require.NoError(t, err)
+
+	// the base64 is HTML escaped
+	assert.Contains(t, html.UnescapeString(string(sheet)), string(encodedKey))
+	assert.Contains(t, string(sheet), "<li>"+words[len(words)-1]+"</li>")
+	assert.Contains(t, string(sheet), "<tr><td>Threshold</td><td>2</td></tr>")
+	assert.Contains(t, string(sheet), "<tr><td>Secret</td><td>secret</td></tr>")
+	assert.Contains(t, string(sheet), "stego decrypt -f secret.enc --key 002.key")
+	assert.Contains(t, string(sheet), `src="data:image/png;base64,`)
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"print", "-o", "sheets", "out/001.key"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "sheets/001.sheet.html")
+
+	require.NoError(t, os.RemoveAll("sheets"))
+}
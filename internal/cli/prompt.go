@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// stdin is the buffered reader of the command input, shared by all the prompts
+// so that the buffered data is not lost between them.
+var stdin struct {
+	source io.Reader
+	reader *bufio.Reader
+}
+
+func stdinReader(cmd *cobra.Command) *bufio.Reader {
+	if in := cmd.InOrStdin(); stdin.source != in {
+		stdin.source = in
+		stdin.reader = bufio.NewReader(in)
+	}
+
+	return stdin.reader
+}
+
+// terminalFd returns the file descriptor of the command input if it is a terminal.
+func terminalFd(cmd *cobra.Command) (int, bool) {
+	f, ok := cmd.InOrStdin().(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0, false
+	}
+
+	return int(f.Fd()), true
+}
+
+// stdinIsContent reports whether the command input is the content to process (the "-" file),
+// and not the answers to the prompts.
+func stdinIsContent(cmd *cobra.Command) bool {
+	f := cmd.Flags().Lookup("file")
+
+	return f != nil && f.Value.String() == "-"
+}
+
+// readTerminalPassword reads a password from the terminal, when the command input is the content to process.
+func readTerminalPassword(cmd *cobra.Command) ([]byte, error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		if cmd.Flags().Lookup("passphrase-file") != nil {
+			return nil, errors.New("no terminal to ask the password, the input is the content to process " +
+				"(use --passphrase-file)")
+		}
+
+		return nil, errors.New("no terminal to ask the password, the input is the content to process")
+	}
+	defer tty.Close()
+
+	return term.ReadPassword(int(tty.Fd()))
+}
+
+// readPassword prompts for a password, without echoing it if the input is a terminal.
+// Otherwise a line is read from the input. If the input is the content to process the terminal is used.
+func readPassword(cmd *cobra.Command, prompt string) ([]byte, error) {
+	fmt.Fprint(cmd.ErrOrStderr(), prompt)
+
+	var (
+		password []byte
+		err      error
+	)
+
+	fd, isTerminal := terminalFd(cmd)
+
+	switch {
+	case stdinIsContent(cmd):
+		password, err = readTerminalPassword(cmd)
+		fmt.Fprintln(cmd.ErrOrStderr())
+	case isTerminal:
+		password, err = term.ReadPassword(fd)
+		fmt.Fprintln(cmd.ErrOrStderr())
+	default:
+		password, err = stdinReader(cmd).ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(password) > 0 {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading password")
+	}
+
+	password = bytes.TrimRight(password, "\r\n")
+	if len(password) == 0 {
+		return nil, errors.New("empty password")
+	}
+
+	return password, nil
+}
+
+// readNewPassword prompts for a new password, asking to enter it twice.
+func readNewPassword(cmd *cobra.Command, prompt string) ([]byte, error) {
+	password, err := readPassword(cmd, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	confirm, err := readPassword(cmd, "Confirm: ")
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(password, confirm) {
+		return nil, errors.New("the passwords do not match")
+	}
+
+	return password, nil
+}
+
+// readNewSecret reads the secret message from the terminal, without echoing it, asking to enter it twice.
+// The message can span multiple lines, and it ends with Ctrl-D.
+func readNewSecret(cmd *cobra.Command, fd int) ([]byte, error) {
+	fmt.Fprint(cmd.ErrOrStderr(), "Enter text (hidden, end with Ctrl-D): ")
+
+	secret, err := readHiddenText(cmd, fd)
+	fmt.Fprintln(cmd.ErrOrStderr())
+
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprint(cmd.ErrOrStderr(), "Confirm text: ")
+
+	confirm, err := readHiddenText(cmd, fd)
+	fmt.Fprintln(cmd.ErrOrStderr())
+
+	if err != nil {
+		return nil, err
+	}
+
+	match := bytes.Equal(secret, confirm)
+
+	// the confirmation is not needed anymore
+	for i := range confirm {
+		confirm[i] = 0
+	}
+
+	if !match {
+		return nil, errors.New("the texts do not match")
+	}
+
+	return secret, nil
+}
+
+// readHiddenText reads the text from the terminal until Ctrl-D, with the terminal in raw mode
+// so that nothing is echoed and the new lines are part of the text.
+func readHiddenText(cmd *cobra.Command, fd int) ([]byte, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed setting terminal in raw mode")
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	reader := stdinReader(cmd)
+	text := []byte{}
+	carriageReturn := false
+
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading text from terminal")
+		}
+
+		// the CRLF of a pasted text is a single new line
+		if c == '\n' && carriageReturn {
+			carriageReturn = false
+
+			continue
+		}
+
+		carriageReturn = c == '\r'
+
+		switch c {
+		case ctrlC:
+			return nil, errors.New("interrupted")
+		case ctrlD:
+			if len(bytes.TrimSpace(text)) == 0 {
+				return nil, errors.New("empty text")
+			}
+
+			return text, nil
+		case '\r', '\n':
+			text = append(text, '\n')
+		case backspace, del:
+			// only the current line can be edited
+			if len(text) > 0 && text[len(text)-1] != '\n' {
+				_, size := utf8.DecodeLastRune(text)
+				text = text[:len(text)-size]
+			}
+		default:
+			text = append(text, c)
+		}
+	}
+}
+
+// The control characters handled by readHiddenText.
+const (
+	ctrlC     = 0x03
+	ctrlD     = 0x04
+	backspace = 0x08
+	del       = 0x7f
+)
+
+// readPassphraseFile reads the passphrase from the first line of the file.
+func readPassphraseFile(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading passphrase file '%s'", filename)
+	}
+
+	passphrase, _, _ := bytes.Cut(content, []byte("\n"))
+
+	passphrase = bytes.TrimRight(passphrase, "\r")
+	if len(passphrase) == 0 {
+		return nil, errors.Errorf("empty passphrase in file '%s'", filename)
+	}
+
+	return passphrase, nil
+}
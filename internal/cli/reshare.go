@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/enrichman/stegosecrets/internal/encrypt"
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newReshareCmd() *cobra.Command {
+	reshareCmd := &cobra.Command{
+		Use:   "reshare",
+		Short: "Split again a key into a new set of parts, without decrypting the secret",
+		Long: `Split again a key into a new set of parts, without decrypting the secret.
+The key is recovered in memory from the provided keys or images, and a new share set is created
+with the new parts and threshold. If the encrypted file is provided it will be updated with the new
+share set, and the old parts will be refused to decrypt it.
+
+The revocation is advisory: the master key does not change and the share set is not authenticated,
+so a threshold of old parts can still recover the key. To really revoke the old parts decrypt the secret
+and encrypt it again, with a new master key.`,
+		RunE: runReshareCmd,
+	}
+
+	reshareCmd.Flags().StringArrayVar(&keyFiles, "key", []string{}, "The files containing the current partial keys")
+	reshareCmd.Flags().StringArrayVar(&imageFiles, "img", []string{}, "The image files containing the current hidden partial keys")
+	reshareCmd.Flags().StringArrayVar(&qrFiles, "qr", []string{}, "The QR code images of the current partial keys")
+	reshareCmd.Flags().BoolVar(&usePIN, "pin", false, "Ask the PIN of every image, for images protected by a PIN (see --stego lsb-scatter)")
+	reshareCmd.Flags().StringVarP(&encryptedFile, "file", "f", "",
+		`The encrypted file to update with the new share set.`)
+	reshareCmd.Flags().Uint8VarP(&keyParts, "parts", "p", 0,
+		`The number of new parts (partial keys) in which the key will be splitted.`)
+	reshareCmd.Flags().Uint8VarP(&keyThreshold, "threshold", "t", 0,
+		`The minimum number of new parts (partial keys) needed to recover the key`)
+	reshareCmd.Flags().StringVarP(&outputDir, "output", "o", "out",
+		`The output directory where the new keys/images will be saved.`)
+	reshareCmd.Flags().StringVarP(&imagesDir, "images", "i", "images",
+		`The directory where to look for the images where the new partial keys will be hidden.
+If empty no images will be generated.`)
+	reshareCmd.Flags().BoolVar(&holderPassphrases, "holder-passphrase", false,
+		`Encrypt every partial key with a passphrase of its holder, asked for every part.
+The passphrase will be asked when the partial key is used.`)
+	reshareCmd.Flags().BoolVar(&verifiable, "verifiable", false,
+		`Split the key into verifiable parts: every holder can check its part against the commitments
+saved along with the encrypted file (see verify-share).`)
+	reshareCmd.Flags().StringVar(&keyFormat, "format", encrypt.KeyFormatBase64,
+		fmt.Sprintf("The format of the partial key files [%s %s].\nThe mnemonic is a list of words, easier to write on paper.",
+			encrypt.KeyFormatBase64, encrypt.KeyFormatMnemonic))
+	reshareCmd.Flags().BoolVar(&qrCodes, "qr-code", false,
+		`Write every partial key also as a QR code image (NNN.qr.png), for paper backups.`)
+	reshareCmd.Flags().BoolVar(&qrCodesSVG, "qr-svg", false,
+		`Write the QR codes also as SVG images (NNN.qr.svg). It implies --qr-code.`)
+	reshareCmd.Flags().BoolVar(&armored, "armor", false,
+		`Write the new partial keys as ASCII armored text (-----BEGIN STEGO PARTIAL KEY-----),
+to paste them in emails, tickets or configuration files.`)
+	reshareCmd.Flags().StringVar(&stegoBackend, "stego", image.DefaultBackend,
+		fmt.Sprintf("The steganography backend used to hide the partial keys into the images %v", image.Backends()))
+
+	return reshareCmd
+}
+
+func runReshareCmd(cmd *cobra.Command, _ []string) error {
+	if keyParts < 2 {
+		return errors.New("at least 2 parts are needed to reshare a key. Use -p/--parts flag")
+	}
+
+	if keyThreshold > keyParts {
+		return errors.Errorf("threshold %d cannot exceed the parts %d", keyThreshold, keyParts)
+	}
+
+	decrypter, err := buildDecrypter(cmd)
+	if err != nil {
+		return errors.Wrap(err, "failed building decrypter")
+	}
+
+	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
+
+	pins, err := getImagePINs(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	partPassphrases, err := getPartPassphrases(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(keyParts, keyThreshold),
+		encrypt.WithOutputDir(outputDir),
+		encrypt.WithImagesDir(imagesDir),
+		encrypt.WithSteganographer(stegoBackend),
+		encrypt.WithImagePINs(pins),
+		encrypt.WithPartPassphrases(partPassphrases),
+		encrypt.WithVerifiable(verifiable),
+		encrypt.WithKeyFormat(keyFormat),
+		encrypt.WithQRCodes(qrCodes, qrCodesSVG),
+		encrypt.WithArmor(armored),
+		encrypt.WithLogger(logger),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed creating encrypter")
+	}
+
+	err = encrypter.Reshare(decrypter.Parts, encryptedFile)
+	if err != nil {
+		return errors.Wrap(err, "failed resharing key")
+	}
+
+	logger.Print("New partial keys saved to:", encrypter.OutputDir)
+
+	return nil
+}
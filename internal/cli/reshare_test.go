@@ -0,0 +1,96 @@
+package cli_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReshareCmd(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	outAndErr := &bytes.Buffer{}
+
+	// every command is executed with a new root command to avoid reusing the flags of the previous one
+	execute := func(args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetIn(strings.NewReader("hello\n"))
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, execute("encrypt", "-p", "3", "-t", "2", "-i", testAssetsDir), outAndErr)
+
+	err := execute(
+		"reshare",
+		"--key", "out/001.key", "--img", "out/002.png",
+		"-p", "4", "-t", "3",
+		"-o", "out/new",
+		"-f", "out/secret.enc",
+		"-i", testAssetsDir,
+	)
+	require.NoError(t, err, outAndErr)
+
+	assert.FileExists(t, "out/new/004.key")
+
+	// the old parts are revoked
+	err = execute("decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--key", "out/002.key")
+	require.ErrorContains(t, err, "revoked")
+
+	// every part is checked, not only the first one
+	err = execute(
+		"decrypt", "-f", "out/secret.enc",
+		"--key", "out/new/001.key", "--key", "out/new/002.key", "--key", "out/new/003.key", "--key", "out/003.key",
+	)
+	require.ErrorContains(t, err, "revoked")
+
+	err = execute(
+		"decrypt", "-f", "out/secret.enc",
+		"--key", "out/new/001.key", "--key", "out/new/002.key", "--img", "out/new/003.png",
+	)
+	require.NoError(t, err, outAndErr)
+}
+
+func TestReshareCmd_NotVerifiable(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	outAndErr := &bytes.Buffer{}
+
+	execute := func(args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetIn(strings.NewReader("hello\n"))
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	require.NoError(t, execute("encrypt", "-p", "3", "-t", "2", "--verifiable", "-i", testAssetsDir), outAndErr)
+	require.FileExists(t, "out/secret.commitments")
+
+	// the key is reshared into parts that are not verifiable
+	err := execute(
+		"reshare",
+		"--key", "out/001.key", "--key", "out/002.key",
+		"-p", "3", "-t", "2",
+		"-o", "out/new",
+		"-f", "out/secret.enc",
+	)
+	require.NoError(t, err, outAndErr)
+
+	// the old commitments would not verify the new parts
+	assert.NoFileExists(t, "out/secret.commitments")
+
+	err = execute("decrypt", "-f", "out/secret.enc", "--key", "out/new/001.key", "--key", "out/new/002.key")
+	require.NoError(t, err, outAndErr)
+}
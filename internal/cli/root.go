@@ -1,8 +1,8 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -33,6 +33,13 @@ The partial keys will also be hidden inside images, adding an additional layer o
 	rootCmd.AddCommand(
 		newEncryptCmd(),
 		newDecryptCmd(),
+		newInspectCmd(),
+		newPrintCmd(),
+		newVerifyCmd(),
+		newVerifyShareCmd(),
+		newSplitCmd(),
+		newCombineCmd(),
+		newReshareCmd(),
 		newImagesCmd(),
 		newVersionCmd(),
 	)
@@ -40,12 +47,20 @@ The partial keys will also be hidden inside images, adding an additional layer o
 	return rootCmd
 }
 
+// getInputFromStdin reads the message to encrypt. From a terminal the message is read without echoing it,
+// until EOF, and it is asked twice. Otherwise a line is read from the input (the last one can miss the new line).
 func getInputFromStdin(cmd *cobra.Command) ([]byte, error) {
+	if fd, ok := terminalFd(cmd); ok {
+		return readNewSecret(cmd, fd)
+	}
+
 	fmt.Fprintf(cmd.OutOrStdout(), "Enter text: ")
 
-	reader := bufio.NewReader(cmd.InOrStdin())
+	text, err := stdinReader(cmd).ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(text) > 0 {
+		err = nil
+	}
 
-	text, err := reader.ReadBytes('\n')
 	if err != nil {
 		return nil, errors.Wrap(err, "failed reading bytes from stdin")
 	}
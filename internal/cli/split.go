@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/enrichman/stegosecrets/internal/encrypt"
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var secretFile string
+
+func newSplitCmd() *cobra.Command {
+	splitCmd := &cobra.Command{
+		Use:   "split",
+		Short: "Split a secret (i.e. a passphrase or a key) into partial keys, without encrypting any file",
+		RunE:  runSplitCmd,
+	}
+
+	splitCmd.Flags().StringVarP(&secretFile, "file", "f", "",
+		`The file containing the secret to split, "-" to read the whole secret from STDIN (i.e. from a pipe).
+If not specified the secret will be asked (hidden if STDIN is a terminal).`)
+	splitCmd.Flags().Uint8VarP(&keyParts, "parts", "p", 0,
+		`The number of parts (partial keys) in which the secret will be splitted.`)
+	splitCmd.Flags().Uint8VarP(&keyThreshold, "threshold", "t", 0,
+		`The minimum number of parts (partial keys) needed to recover the secret`)
+	splitCmd.Flags().StringVarP(&outputDir, "output", "o", "out",
+		`The output directory where the keys/images will be saved.`)
+	splitCmd.Flags().StringVarP(&imagesDir, "images", "i", "images",
+		`The directory where to look for the images where the partial keys will be hidden.
+If empty no images will be generated.`)
+	splitCmd.Flags().BoolVar(&holderPassphrases, "holder-passphrase", false,
+		`Encrypt every partial key with a passphrase of its holder, asked for every part.
+The passphrase will be asked when the partial key is used.`)
+	splitCmd.Flags().BoolVar(&verifiable, "verifiable", false,
+		`Split the key into verifiable parts: every holder can check its part against the commitments
+saved along with the encrypted file (see verify-share).`)
+	splitCmd.Flags().StringVar(&keyFormat, "format", encrypt.KeyFormatBase64,
+		fmt.Sprintf("The format of the partial key files [%s %s].\nThe mnemonic is a list of words, easier to write on paper.",
+			encrypt.KeyFormatBase64, encrypt.KeyFormatMnemonic))
+	splitCmd.Flags().BoolVar(&qrCodes, "qr-code", false,
+		`Write every partial key also as a QR code image (NNN.qr.png), for paper backups.`)
+	splitCmd.Flags().BoolVar(&qrCodesSVG, "qr-svg", false,
+		`Write the QR codes also as SVG images (NNN.qr.svg). It implies --qr-code.`)
+	splitCmd.Flags().BoolVar(&armored, "armor", false,
+		`Write the partial keys as ASCII armored text (-----BEGIN STEGO PARTIAL KEY-----),
+to paste them in emails, tickets or configuration files.`)
+	splitCmd.Flags().StringVar(&stegoBackend, "stego", image.DefaultBackend,
+		fmt.Sprintf("The steganography backend used to hide the partial keys into the images %v", image.Backends()))
+
+	return splitCmd
+}
+
+func runSplitCmd(cmd *cobra.Command, _ []string) error {
+	if keyParts < 2 {
+		return errors.New("at least 2 parts are needed to split a secret. Use -p/--parts flag")
+	}
+
+	if keyThreshold > keyParts {
+		return errors.Errorf("threshold %d cannot exceed the parts %d", keyThreshold, keyParts)
+	}
+
+	var (
+		secret []byte
+		err    error
+	)
+
+	// the secret is read as the content to encrypt: the prompts that follow share the same input,
+	// or they use the terminal if the whole input is the secret
+	switch secretFile {
+	case "-":
+		secret, err = io.ReadAll(stdinReader(cmd))
+	case "":
+		secret, err = getInputFromStdin(cmd)
+		// the new line ending the answer is not part of the secret
+		secret = bytes.TrimRight(secret, "\r\n")
+	default:
+		secret, err = file.ReadFile(secretFile)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed reading secret to split")
+	}
+
+	if len(secret) == 0 {
+		return errors.New("empty secret")
+	}
+
+	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
+
+	pins, err := getImagePINs(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	partPassphrases, err := getPartPassphrases(cmd, keyParts)
+	if err != nil {
+		return err
+	}
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(keyParts, keyThreshold),
+		encrypt.WithOutputDir(outputDir),
+		encrypt.WithImagesDir(imagesDir),
+		encrypt.WithSteganographer(stegoBackend),
+		encrypt.WithImagePINs(pins),
+		encrypt.WithPartPassphrases(partPassphrases),
+		encrypt.WithVerifiable(verifiable),
+		encrypt.WithKeyFormat(keyFormat),
+		encrypt.WithQRCodes(qrCodes, qrCodesSVG),
+		encrypt.WithArmor(armored),
+		encrypt.WithLogger(logger),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed creating encrypter")
+	}
+
+	err = encrypter.SplitAndSaveKey(secret)
+	if err != nil {
+		return errors.Wrap(err, "failed splitting secret")
+	}
+
+	logger.Print("Partial keys saved to:", encrypter.OutputDir)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/internal/verify"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	verifyCmd := &cobra.Command{
+		Use:   "verify [DIR]",
+		Short: "Verify the checksums and the shares of an output directory (default 'out')",
+		Long: `Verify the checksums and the shares of an output directory (default 'out').
+Every checksum file is checked, every image and partial key must contain a valid share,
+and all the shares must have the same parameters (version, parts and threshold) and unique tags.
+The images protected by a PIN (see --stego lsb-scatter) can be verified only with --pin.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runVerifyCmd,
+	}
+
+	verifyCmd.Flags().BoolVar(&usePIN, "pin", false, "Ask the PIN of every image, for images protected by a PIN (see --stego lsb-scatter)")
+
+	return verifyCmd
+}
+
+func runVerifyCmd(cmd *cobra.Command, args []string) error {
+	dir := "out"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
+
+	var pin verify.PINFunc
+	if usePIN {
+		pin = func(filename string) ([]byte, error) {
+			return readPassword(cmd, fmt.Sprintf("Enter PIN for image '%s': ", filename))
+		}
+	}
+
+	report, err := verify.Dir(dir, pin)
+	if err != nil {
+		return errors.Wrapf(err, "failed verifying directory '%s'", dir)
+	}
+
+	for _, result := range report {
+		if result.Err != nil {
+			logger.Print(result)
+		} else {
+			logger.Debug(result)
+		}
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		if !usePIN {
+			logger.Print("The images protected by a PIN can be verified only with --pin")
+		}
+
+		return errors.Errorf("verification failed: %d of %d checks failed", failed, len(report))
+	}
+
+	logger.Print("✅ All", len(report), "checks passed")
+
+	return nil
+}
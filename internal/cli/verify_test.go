@@ -0,0 +1,64 @@
+package cli_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCmd(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+
+	out := &bytes.Buffer{}
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(out)
+
+	rootCmd.SetArgs([]string{"verify", testAssetsDir})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, out)
+}
+
+func TestVerifyCmd_NotExistingDir(t *testing.T) {
+	rootCmd := cli.NewRootCmd()
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	rootCmd.SetArgs([]string{"verify", "not-existing-dir"})
+
+	err := rootCmd.Execute()
+	assert.Error(t, err)
+}
+
+func TestVerifyCmd_PIN(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	outAndErr := &bytes.Buffer{}
+
+	execute := func(stdin string, args ...string) error {
+		rootCmd := cli.NewRootCmd()
+		rootCmd.SetOut(outAndErr)
+		rootCmd.SetErr(outAndErr)
+		rootCmd.SetIn(strings.NewReader(stdin))
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	err := execute("hello\n1111\n1111\n2222\n2222\n3333\n3333\n",
+		"encrypt", "-p", "3", "-t", "2", "-i", "../../test/assets/p5t3", "--stego", "lsb-scatter")
+	require.NoError(t, err, outAndErr)
+
+	require.Error(t, execute("", "verify"))
+	assert.Contains(t, outAndErr.String(), "--pin")
+
+	err = execute("1111\n2222\n3333\n", "verify", "--pin")
+	require.NoError(t, err, outAndErr)
+
+	require.Error(t, execute("1111\n", "verify", "--pin"))
+}
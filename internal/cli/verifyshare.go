@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/internal/verify"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var commitmentsFile string
+
+func newVerifyShareCmd() *cobra.Command {
+	verifyShareCmd := &cobra.Command{
+		Use:   "verify-share",
+		Short: "Verify partial keys or images against the commitments, without recovering the secret",
+		Long: `Verify partial keys or images against the commitments, without recovering the secret.
+The commitments are saved along with the encrypted file when the key is split into verifiable parts
+(see the --verifiable flag): every holder can check that its part is consistent with the others.`,
+		RunE: runVerifyShareCmd,
+	}
+
+	verifyShareCmd.Flags().StringVarP(&commitmentsFile, "commitments", "c", "", "The commitments file")
+	verifyShareCmd.Flags().StringArrayVar(&keyFiles, "key", []string{}, "The files containing the partial keys")
+	verifyShareCmd.Flags().StringArrayVar(&imageFiles, "img", []string{}, "The image files containing the hidden partial keys")
+	verifyShareCmd.Flags().StringArrayVar(&qrFiles, "qr", []string{}, "The QR code images of the partial keys")
+	verifyShareCmd.Flags().BoolVar(&usePIN, "pin", false, "Ask the PIN of every image, for images protected by a PIN (see --stego lsb-scatter)")
+
+	return verifyShareCmd
+}
+
+func runVerifyShareCmd(cmd *cobra.Command, _ []string) error {
+	if commitmentsFile == "" {
+		return errors.New("the commitments file is required. Use -c/--commitments flag")
+	}
+
+	if len(keyFiles) == 0 && len(imageFiles) == 0 && len(qrFiles) == 0 {
+		return errors.New("no partial keys to verify. Use --key, --img or --qr flags")
+	}
+
+	commitments, err := verify.ReadCommitmentsFile(commitmentsFile)
+	if err != nil {
+		return err
+	}
+
+	decrypter, err := buildDecrypter(cmd)
+	if err != nil {
+		return errors.Wrap(err, "failed reading partial keys")
+	}
+
+	parts := map[string]sss.Part{}
+	for i, part := range decrypter.Parts {
+		parts[decrypter.PartFiles[i]] = part
+	}
+
+	logger := log.NewSimpleLogger(cmd.OutOrStdout(), log.NewLevel(silent, verbose))
+
+	report := verify.Shares(commitments, parts)
+	for _, result := range report {
+		logger.Print(result)
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		return errors.Errorf("verification failed: %d of %d partial keys are not consistent", failed, len(report))
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyShareCmd(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown(t)
+
+	rootCmd := cli.NewRootCmd()
+
+	outAndErr := &bytes.Buffer{}
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetIn(strings.NewReader("hello\n"))
+	rootCmd.SetArgs([]string{"encrypt", "-p", "3", "-t", "2", "-i", testAssetsDir, "--verifiable"})
+
+	err := rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+	assert.FileExists(t, "out/secret.commitments")
+
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"verify-share", "-c", "out/secret.commitments", "--key", "out/001.key", "--img", "out/002.png"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	// the output directory is verified against the commitments as well
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"verify", "out"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	// corrupt the last byte of a partial key
+	encoded, err := os.ReadFile("out/003.key")
+	require.NoError(t, err)
+
+	content, err := base64.StdEncoding.DecodeString(string(encoded))
+	require.NoError(t, err)
+
+	content[len(content)-1] ^= 1
+	require.NoError(t, os.WriteFile("out/003.key", []byte(base64.StdEncoding.EncodeToString(content)), 0o600))
+
+	out := &bytes.Buffer{}
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(out)
+	rootCmd.SetArgs([]string{"verify-share", "-c", "out/secret.commitments", "--key", "out/001.key", "--key", "out/003.key"})
+
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "❌ out/003.key [commitments]")
+
+	// the verifiable parts decrypt the file
+	rootCmd = cli.NewRootCmd()
+	rootCmd.SetOut(outAndErr)
+	rootCmd.SetErr(outAndErr)
+	rootCmd.SetArgs([]string{"decrypt", "-f", "out/secret.enc", "--key", "out/001.key", "--img", "out/002.png"})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err, outAndErr)
+
+	decrypted, err := os.ReadFile("out/secret")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(decrypted))
+}
@@ -1,13 +1,19 @@
 package decrypt
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/pkg/archive"
+	"github.com/enrichman/stegosecrets/pkg/armor"
 	"github.com/enrichman/stegosecrets/pkg/file"
 	"github.com/enrichman/stegosecrets/pkg/image"
 	sss "github.com/enrichman/stegosecrets/pkg/stego"
@@ -19,13 +25,37 @@ type Decrypter struct {
 
 	MasterKey []byte
 	Parts     []sss.Part
+	// PartFiles are the files of the parts (in the same order), to report the bad ones
+	PartFiles []string
+
+	// Passphrase derives the master key with the KDF parameters stored in the header
+	Passphrase []byte
+	// PartPassphrase returns the passphrase of the holder of a part read from the file, if protected
+	PartPassphrase func(filename string) ([]byte, error)
+
+	// Legacy enables the unauthenticated AES-CFB cipher used by older releases
+	Legacy bool
+
+	// ChecksumFile and EncryptedChecksumFile are the checksum files of the decrypted and encrypted file.
+	// If empty they are looked up next to the encrypted file, and skipped if not found.
+	ChecksumFile          string
+	EncryptedChecksumFile string
+	// Force writes the decrypted file even if the checksum verification fails
+	Force bool
+
+	// OutputFile is the file where the decrypted content is saved.
+	// If empty the original name stored in the encrypted file is used.
+	OutputFile string
+	// ExtractDir is the directory where the decrypted directory archive is extracted
+	ExtractDir string
 }
 
 type OptFunc func(*Decrypter) error
 
 func NewDecrypter(opts ...OptFunc) (*Decrypter, error) {
 	decrypter := &Decrypter{
-		Parts: []sss.Part{},
+		Parts:     []sss.Part{},
+		PartFiles: []string{},
 	}
 
 	for _, opt := range opts {
@@ -51,6 +81,74 @@ func WithMasterKeyFile(filename string) OptFunc {
 	}
 }
 
+// WithPassphrase derives the master key from the passphrase, for files encrypted in passphrase mode.
+func WithPassphrase(passphrase []byte) OptFunc {
+	return func(d *Decrypter) error {
+		if len(passphrase) == 0 {
+			return errors.New("empty passphrase")
+		}
+
+		d.Passphrase = passphrase
+
+		return nil
+	}
+}
+
+// WithLegacyCipher decrypts files created by older releases with the AES-CFB cipher.
+func WithLegacyCipher() OptFunc {
+	return func(d *Decrypter) error {
+		d.Legacy = true
+
+		return nil
+	}
+}
+
+// WithChecksumFile sets the checksum file used to verify the decrypted file.
+func WithChecksumFile(filename string) OptFunc {
+	return func(d *Decrypter) error {
+		d.ChecksumFile = filename
+
+		return nil
+	}
+}
+
+// WithEncryptedChecksumFile sets the checksum file used to verify the encrypted file before decrypting it.
+func WithEncryptedChecksumFile(filename string) OptFunc {
+	return func(d *Decrypter) error {
+		d.EncryptedChecksumFile = filename
+
+		return nil
+	}
+}
+
+// WithForce writes the decrypted file even if the checksum verification fails.
+func WithForce() OptFunc {
+	return func(d *Decrypter) error {
+		d.Force = true
+
+		return nil
+	}
+}
+
+// WithOutputFile sets the file where the decrypted content is saved.
+func WithOutputFile(filename string) OptFunc {
+	return func(d *Decrypter) error {
+		d.OutputFile = filename
+
+		return nil
+	}
+}
+
+// WithExtractDir sets the directory where the decrypted directory archive is extracted,
+// instead of saving the archive.
+func WithExtractDir(dir string) OptFunc {
+	return func(d *Decrypter) error {
+		d.ExtractDir = dir
+
+		return nil
+	}
+}
+
 func WithPartialKeyFiles(filenames []string) OptFunc {
 	return func(d *Decrypter) error {
 		for _, filename := range filenames {
@@ -66,51 +164,191 @@ func WithPartialKeyFiles(filenames []string) OptFunc {
 
 func WithPartialKeyFile(filename string) OptFunc {
 	return func(d *Decrypter) error {
-		partialKey, err := file.ReadKey(filename)
+		content, err := readPartialKeyFile(filename)
 		if err != nil {
-			return errors.Wrap(err, "failed reading partial key file")
+			return err
 		}
 
-		part, err := sss.NewPartFromContent(partialKey)
+		return d.addPart(filename, content)
+	}
+}
+
+// WithPartialKeyQRFile reads the part from the QR code in the image file (see file.WriteQRKey).
+func WithPartialKeyQRFile(filename string) OptFunc {
+	return func(d *Decrypter) error {
+		content, err := file.ReadQRKey(filename)
 		if err != nil {
-			return errors.Wrap(err, "failed creating part")
+			return errors.Wrap(err, "failed reading partial key QR code")
 		}
 
-		d.Parts = append(d.Parts, part)
-
-		return nil
+		return d.addPart(filename, content)
 	}
 }
 
 func WithPartialKeyImageFile(filename string) OptFunc {
 	return func(d *Decrypter) error {
-		file, err := os.Open(filename)
+		content, err := readPartialKeyImageFile(filename, nil)
 		if err != nil {
-			return errors.Wrapf(err, "failed opening file '%s'", filename)
+			return err
 		}
-		defer file.Close()
 
-		partialKey, err := image.DecodeSecret(file)
+		return d.addPart(filename, content)
+	}
+}
+
+// WithPartialKeyImageFilePIN reads the part hidden in an image with a backend using the PIN of the holder.
+func WithPartialKeyImageFilePIN(filename string, pin []byte) OptFunc {
+	return func(d *Decrypter) error {
+		content, err := readPartialKeyImageFile(filename, pin)
 		if err != nil {
-			return errors.Wrap(err, "failed reading partial key image file")
+			return err
 		}
 
-		part, err := sss.NewPartFromContent(partialKey)
+		return d.addPart(filename, content)
+	}
+}
+
+// WithPartPassphraseFunc sets the function returning the passphrase of the holder of a part
+// protected by a passphrase. It has to precede the options reading the parts.
+func WithPartPassphraseFunc(passphraseFunc func(filename string) ([]byte, error)) OptFunc {
+	return func(d *Decrypter) error {
+		d.PartPassphrase = passphraseFunc
+
+		return nil
+	}
+}
+
+// addPart adds the part read from the file, decrypting it with the passphrase of its holder if needed.
+func (d *Decrypter) addPart(filename string, content []byte) error {
+	part, err := sss.NewPartFromContent(content)
+	if errors.Is(err, sss.ErrWrappedPart) && d.PartPassphrase != nil {
+		passphrase, err := d.PartPassphrase(filename)
 		if err != nil {
-			return errors.Wrap(err, "failed creating part")
+			return errors.Wrapf(err, "failed getting passphrase of partial key '%s'", filename)
+		}
+
+		part, err = sss.UnwrapPart(content, passphrase)
+		if errors.Is(err, sss.ErrAuthenticationFailed) {
+			return errors.Errorf("wrong passphrase for partial key '%s'", filename)
 		}
 
-		d.Parts = append(d.Parts, part)
+		if err != nil {
+			return errors.Wrapf(err, "failed decrypting partial key '%s'", filename)
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed creating part from '%s'", filename)
+	}
 
-		return nil
+	d.Parts = append(d.Parts, part)
+	d.PartFiles = append(d.PartFiles, filename)
+
+	return nil
+}
+
+// ReadPartialKeyFile reads a part from a partial key file.
+// It returns sss.ErrWrappedPart if the part is protected by the passphrase of its holder.
+func ReadPartialKeyFile(filename string) (sss.Part, error) {
+	partialKey, err := readPartialKeyFile(filename)
+	if err != nil {
+		return sss.Part{}, err
+	}
+
+	part, err := sss.NewPartFromContent(partialKey)
+	if err != nil {
+		return sss.Part{}, errors.Wrap(err, "failed creating part")
+	}
+
+	return part, nil
+}
+
+// ReadPartialKeyQRFile reads a part from the QR code in the image file.
+// It returns sss.ErrWrappedPart if the part is protected by the passphrase of its holder.
+func ReadPartialKeyQRFile(filename string) (sss.Part, error) {
+	partialKey, err := file.ReadQRKey(filename)
+	if err != nil {
+		return sss.Part{}, errors.Wrap(err, "failed reading partial key QR code")
+	}
+
+	part, err := sss.NewPartFromContent(partialKey)
+	if err != nil {
+		return sss.Part{}, errors.Wrap(err, "failed creating part")
+	}
+
+	return part, nil
+}
+
+func readPartialKeyFile(filename string) ([]byte, error) {
+	partialKey, err := file.ReadKey(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading partial key file")
+	}
+
+	return partialKey, nil
+}
+
+// ReadPartialKeyImageFile reads a part hidden in an image.
+// It returns sss.ErrWrappedPart if the part is protected by the passphrase of its holder.
+func ReadPartialKeyImageFile(filename string) (sss.Part, error) {
+	partialKey, err := readPartialKeyImageFile(filename, nil)
+	if err != nil {
+		return sss.Part{}, err
+	}
+
+	part, err := sss.NewPartFromContent(partialKey)
+	if errors.Is(err, sss.ErrWrappedPart) {
+		return sss.Part{}, errors.Wrap(err, "failed creating part")
+	}
+
+	if err != nil {
+		return sss.Part{}, errors.Wrap(err, "failed creating part (is the image protected by a PIN?)")
+	}
+
+	return part, nil
+}
+
+// ReadPartialKeyImageFilePIN reads a part hidden in an image with a backend using the PIN of the holder.
+func ReadPartialKeyImageFilePIN(filename string, pin []byte) (sss.Part, error) {
+	partialKey, err := readPartialKeyImageFile(filename, pin)
+	if err != nil {
+		return sss.Part{}, err
+	}
+
+	part, err := sss.NewPartFromContent(partialKey)
+	if err != nil {
+		return sss.Part{}, errors.Wrap(err, "failed creating part")
+	}
+
+	return part, nil
+}
+
+// readPartialKeyImageFile returns the content hidden in the image, using the PIN if provided.
+func readPartialKeyImageFile(filename string, pin []byte) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer file.Close()
+
+	var partialKey []byte
+
+	if pin != nil {
+		partialKey, _, err = image.DecodeSecretWithKey(file, pin)
+	} else {
+		partialKey, err = image.DecodeSecret(file)
 	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading partial key image file '%s'", filename)
+	}
+
+	return partialKey, nil
 }
 
 func (d *Decrypter) Decrypt(filename string) error {
 	d.Logger.Print(fmt.Sprintf("Decrypting '%s'", filepath.Base(filename)))
 
-	if len(d.MasterKey) == 0 && len(d.Parts) < 2 {
-		return errors.New("at least a master-key or more than one part needs to be specified")
+	if err := d.checkKeys(); err != nil {
+		return err
 	}
 
 	encryptedFile, err := os.Open(filename)
@@ -121,40 +359,405 @@ func (d *Decrypter) Decrypt(filename string) error {
 
 		return errors.Wrapf(err, "failed opening file '%s'", filename)
 	}
+	defer encryptedFile.Close()
+
+	err = d.verifyEncryptedChecksum(filename)
+	if err != nil {
+		return err
+	}
+
+	// the content is decrypted into a temporary file, and it is renamed only after a successful decryption
+	tmpFile, err := os.CreateTemp(d.outputDir(filename), ".stego-*")
+	if err != nil {
+		return errors.Wrap(err, "failed creating temporary file")
+	}
+
+	// the decrypted content is hashed while writing to verify its checksum
+	cleartextHash := sha256.New()
+
+	header, err := d.decryptStream(encryptedFile, io.MultiWriter(tmpFile, cleartextHash))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+
+		return errors.Wrap(err, "failed decrypting content")
+	}
+
+	outputFile := d.OutputFile
+	if outputFile == "" {
+		outputFile = outputFilename(filename, header)
+	}
+
+	err = d.verifyChecksum(cleartextHash, d.checksumFile(filename, header), d.ChecksumFile != "", "decrypted file")
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+
+		return err
+	}
+
+	if d.ExtractDir != "" {
+		return d.extract(tmpFile.Name(), header)
+	}
+
+	err = os.Rename(tmpFile.Name(), outputFile)
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+
+		return errors.Wrap(err, "failed writing decoded file")
+	}
+
+	d.Logger.Debug("Created file:", outputFile)
+	d.Logger.Print("Decrypted file saved to:", outputFile)
+
+	return nil
+}
+
+// outputDir returns the directory where the decrypted content is saved, known before reading the header
+// (the default output file is next to the encrypted file). The temporary file is created there, since
+// it cannot be renamed across filesystems.
+func (d *Decrypter) outputDir(filename string) string {
+	switch {
+	case d.ExtractDir != "":
+		return filepath.Dir(filepath.Clean(d.ExtractDir))
+	case d.OutputFile != "":
+		return filepath.Dir(d.OutputFile)
+	default:
+		return filepath.Dir(filename)
+	}
+}
+
+// extract extracts the decrypted directory archive, removing it.
+func (d *Decrypter) extract(archiveFile string, header *sss.Header) error {
+	defer func() { _ = os.Remove(archiveFile) }()
+
+	if header == nil || !header.ContentType.IsArchive() {
+		return errors.New("the encrypted content is not a directory archive")
+	}
+
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return errors.Wrap(err, "failed opening decrypted archive")
+	}
+	defer f.Close()
+
+	err = archive.Extract(f, d.ExtractDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed extracting archive into '%s'", d.ExtractDir)
+	}
+
+	d.Logger.Print("Decrypted directory extracted to:", d.ExtractDir)
+
+	return nil
+}
+
+// DecryptTo decrypts the file, writing the decrypted content to w (i.e. STDOUT) instead of a file,
+// while it is decrypted: if the decryption or the checksum verification fails the content already written
+// has to be discarded.
+func (d *Decrypter) DecryptTo(filename string, w io.Writer) error {
+	d.Logger.Print(fmt.Sprintf("Decrypting '%s'", filepath.Base(filename)))
+
+	if err := d.checkKeys(); err != nil {
+		return err
+	}
+
+	encryptedFile, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer encryptedFile.Close()
+
+	err = d.verifyEncryptedChecksum(filename)
+	if err != nil {
+		return err
+	}
+
+	cleartextHash := sha256.New()
+
+	header, err := d.decryptStream(encryptedFile, io.MultiWriter(w, cleartextHash))
+	if err != nil {
+		return errors.Wrap(err, "failed decrypting content")
+	}
+
+	return d.verifyChecksum(cleartextHash, d.checksumFile(filename, header), d.ChecksumFile != "", "decrypted file")
+}
+
+// checksumFile returns the checksum file of the decrypted content: the one provided, or the one written
+// next to the encrypted file when it was created (it does not depend on where the content is saved).
+func (d *Decrypter) checksumFile(filename string, header *sss.Header) string {
+	if d.ChecksumFile != "" {
+		return d.ChecksumFile
+	}
+
+	return outputFilename(filename, header) + ".checksum"
+}
+
+// DecryptStream decrypts the content read from r (i.e. STDIN), writing it to w while it is decrypted:
+// if the decryption fails the content already written has to be discarded.
+// The checksum of the decrypted content is verified only if set with WithChecksumFile.
+func (d *Decrypter) DecryptStream(r io.Reader, w io.Writer) error {
+	if err := d.checkKeys(); err != nil {
+		return err
+	}
+
+	cleartextHash := sha256.New()
+
+	_, err := d.decryptStream(r, io.MultiWriter(w, cleartextHash))
+	if err != nil {
+		return errors.Wrap(err, "failed decrypting content")
+	}
+
+	if d.ChecksumFile == "" {
+		return nil
+	}
+
+	return d.verifyChecksum(cleartextHash, d.ChecksumFile, true, "decrypted file")
+}
+
+func (d *Decrypter) checkKeys() error {
+	if len(d.MasterKey) == 0 && len(d.Parts) < 2 && len(d.Passphrase) == 0 {
+		return errors.New("at least a master-key, more than one part or a passphrase needs to be specified")
+	}
+
+	return nil
+}
+
+func (d *Decrypter) verifyEncryptedChecksum(filename string) error {
+	checksumFile := d.EncryptedChecksumFile
+	if checksumFile == "" {
+		checksumFile = filename + ".checksum"
+	}
+
+	if d.EncryptedChecksumFile == "" && !fileExists(checksumFile) {
+		d.Logger.Debug(fmt.Sprintf("Checksum file '%s' not found, skipping verification", checksumFile))
+
+		return nil
+	}
+
+	encryptedHash, err := file.HashFile(filename)
+	if err != nil {
+		return err
+	}
+
+	return d.verifyChecksum(encryptedHash, checksumFile, true, "encrypted file")
+}
+
+// verifyChecksum verifies the hash against the checksum file. If the checksum file was not explicitly
+// provided and it does not exist the verification is skipped. A mismatch is ignored only if forced.
+func (d *Decrypter) verifyChecksum(h hash.Hash, checksumFile string, required bool, name string) error {
+	if !required && !fileExists(checksumFile) {
+		d.Logger.Debug(fmt.Sprintf("Checksum file '%s' not found, skipping verification", checksumFile))
+
+		return nil
+	}
+
+	err := file.CheckHash(h, checksumFile)
+	if err == nil {
+		d.Logger.Debug(fmt.Sprintf("Verified %s checksum '%s'", name, checksumFile))
+
+		return nil
+	}
+
+	if errors.Is(err, file.ErrChecksumMismatch) && d.Force {
+		d.Logger.Print(fmt.Sprintf("⚠️  Ignoring failed %s checksum verification '%s'", name, checksumFile))
+
+		return nil
+	}
+
+	return errors.Wrapf(err, "%s checksum verification failed '%s'", name, checksumFile)
+}
 
-	var key []byte
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
 
-	if len(d.MasterKey) > 0 {
+	return err == nil
+}
+
+// key returns the key to decrypt the file with the given header (nil for files created by older releases).
+func (d *Decrypter) key(header *sss.Header) ([]byte, error) {
+	switch {
+	case len(d.MasterKey) > 0:
 		d.Logger.Print("Decrypting with master-key")
-		key = d.MasterKey
-	} else {
+
+		return d.MasterKey, nil
+	case len(d.Parts) > 0:
 		d.Logger.Print("Decrypting with partial keys")
 
-		key, err = sss.Combine(d.Parts)
+		return d.Combine()
+	default:
+		if header == nil || header.KDF.KDF == sss.KDFNone {
+			return nil, errors.New("the file was not encrypted with a passphrase")
+		}
+
+		d.Logger.Print(fmt.Sprintf("Decrypting with passphrase (%s)", header.KDF.KDF))
+
+		key, err := sss.DeriveKey(d.Passphrase, header.KDF)
 		if err != nil {
-			return errors.Wrap(err, "failed combining parts")
+			return nil, errors.Wrap(err, "failed deriving key from passphrase")
 		}
+
+		return key, nil
+	}
+}
+
+// Combine reconstructs the secret (usually the master key) from the provided parts.
+func (d *Decrypter) Combine() ([]byte, error) {
+	if len(d.Parts) < 2 {
+		return nil, errors.New("more than one part needs to be specified")
 	}
 
-	content, err := io.ReadAll(encryptedFile)
+	key, bad, err := sss.CombineChecked(d.Parts)
 	if err != nil {
-		return errors.Wrap(err, "failed to read content")
+		return nil, errors.Wrap(err, "failed combining parts")
+	}
+
+	for _, part := range bad {
+		for i, p := range d.Parts {
+			// the same part can be read from more files
+			if bytes.Equal(p.Bytes(), part.Bytes()) {
+				d.Logger.Print(fmt.Sprintf("⚠️  Ignoring corrupted or forged partial key '%s' (tag %d)", d.PartFiles[i], part.Tag))
+			}
+		}
 	}
 
-	cleartext, err := sss.Decrypt(key, content)
+	return key, nil
+}
+
+// decryptStream decrypts the content read from the reader into the writer, returning the parsed header
+// (nil for files created by older releases).
+func (d *Decrypter) decryptStream(r io.Reader, w io.Writer) (*sss.Header, error) {
+	// the armored files are decoded while decrypting
+	unwrapped, err := armor.Unwrap(r)
 	if err != nil {
-		return errors.Wrap(err, "failed decrypting content")
+		return nil, errors.Wrap(err, "failed reading armored file")
 	}
 
-	// TODO check checksum
-	outputFile := strings.TrimSuffix(filename, ".enc")
+	reader := bufio.NewReader(unwrapped)
 
-	err = file.WriteFile(d.Logger, cleartext, outputFile)
+	// error ignored: a short content will fail as a missing header
+	prefix, _ := reader.Peek(len(sss.HeaderMagic))
+	if !sss.HasHeader(prefix) {
+		return nil, d.decryptLegacy(reader, w)
+	}
+
+	header, err := sss.ReadHeader(reader)
 	if err != nil {
-		return errors.Wrap(err, "failed writing decoded file")
+		return nil, errors.Wrap(err, "failed reading header")
 	}
 
-	d.Logger.Print("Decrypted file saved to:", outputFile)
+	d.Logger.Debug(fmt.Sprintf("Format version %d, cipher %s", header.Version, header.Cipher))
+
+	err = d.checkShareSet(header)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := d.key(header)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticatedData, err := header.AuthenticatedData()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed marshaling header")
+	}
+
+	var cleartext io.Reader
+
+	switch header.Cipher {
+	case sss.CipherAES256GCM:
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read content")
+		}
+
+		decrypted, err := sss.Open(key, content, authenticatedData)
+		if err != nil {
+			return nil, err
+		}
+
+		cleartext = bytes.NewReader(decrypted)
+	case sss.CipherAES256GCMStream:
+		cleartext, err = sss.DecryptStream(key, authenticatedData, int(header.ChunkSize), reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed creating decryption stream")
+		}
+	default:
+		return nil, errors.Errorf("unsupported cipher suite %d", header.Cipher)
+	}
+
+	size, err := io.Copy(w, cleartext)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.PlaintextSize != sss.UnknownSize && size != header.PlaintextSize {
+		return nil, errors.Errorf("decrypted size %d does not match the expected %d", size, header.PlaintextSize)
+	}
+
+	return header, nil
+}
+
+// checkShareSet checks that all the parts belong to the share set of the encrypted file:
+// when the key is reshared the parts of the previous share sets are refused. The check is advisory,
+// since the share set is not authenticated and the master key does not change.
+func (d *Decrypter) checkShareSet(header *sss.Header) error {
+	if len(d.MasterKey) > 0 || len(d.Parts) == 0 || header.ShareSetID.IsZero() {
+		return nil
+	}
+
+	for _, part := range d.Parts {
+		if part.SetID != header.ShareSetID {
+			return errors.Errorf(
+				"the partial key %d belongs to the share set '%s', but the file requires the share set '%s': it was revoked",
+				part.Tag, part.SetID, header.ShareSetID,
+			)
+		}
+	}
 
 	return nil
 }
+
+func (d *Decrypter) decryptLegacy(r io.Reader, w io.Writer) error {
+	if !d.Legacy {
+		return errors.Wrap(sss.ErrMissingHeader, "files created by older releases need the legacy cipher")
+	}
+
+	key, err := d.key(nil)
+	if err != nil {
+		return err
+	}
+
+	d.Logger.Print("⚠️  Decrypting with the legacy unauthenticated cipher")
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read content")
+	}
+
+	cleartext, err := sss.DecryptLegacy(key, content)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(cleartext)
+
+	return errors.Wrap(err, "failed writing decrypted content")
+}
+
+// outputFilename returns the name of the decrypted file: the encrypted filename without the '.enc' extension,
+// or the original filename stored in the header if the encrypted file was renamed.
+func outputFilename(filename string, header *sss.Header) string {
+	if strings.HasSuffix(filename, ".enc") || header == nil || header.Filename == "" {
+		return strings.TrimSuffix(filename, ".enc")
+	}
+
+	name := filepath.Base(header.Filename)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return filename + ".dec"
+	}
+
+	return filepath.Join(filepath.Dir(filename), name)
+}
@@ -1,25 +1,64 @@
 package encrypt
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/pkg/archive"
+	"github.com/enrichman/stegosecrets/pkg/armor"
 	"github.com/enrichman/stegosecrets/pkg/file"
 	"github.com/enrichman/stegosecrets/pkg/image"
 	sss "github.com/enrichman/stegosecrets/pkg/stego"
 	"github.com/pkg/errors"
 )
 
+// The formats of the partial key files.
+const (
+	KeyFormatBase64   = "base64"
+	KeyFormatMnemonic = "mnemonic"
+)
+
 type Encrypter struct {
 	Parts     uint8
 	Threshold uint8
 	OutputDir string
 	ImagesDir string
 
+	// KeepMasterKey saves the master key also when it is split into parts
+	KeepMasterKey bool
+
+	// Passphrase, if set, is used to derive the master key instead of generating a random one
+	Passphrase []byte
+
+	// Steganographer is the backend used to hide the partial keys into the images
+	Steganographer image.Steganographer
+	// ImagePINs are the PINs of the holders of the images, needed by the backends using a key
+	ImagePINs [][]byte
+	// PartPassphrases are the passphrases of the holders, used to encrypt their parts
+	PartPassphrases [][]byte
+
+	// Verifiable splits the key into verifiable parts, saving the commitments to check them
+	Verifiable bool
+
+	// KeyFormat is the format of the partial key files (KeyFormatBase64 or KeyFormatMnemonic)
+	KeyFormat string
+
+	// QRCodes writes every partial key also as a QR code image, and QRCodesSVG also as an SVG image
+	QRCodes    bool
+	QRCodesSVG bool
+
+	// Armor writes the encrypted file and the partial key files as ASCII armored blocks
+	Armor bool
+
 	Logger log.Logger
 }
 
@@ -46,6 +85,10 @@ func NewEncrypter(opts ...OptFunc) (*Encrypter, error) {
 		}
 	}
 
+	if enc.Steganographer == nil {
+		enc.Steganographer = image.Default()
+	}
+
 	return enc, nil
 }
 
@@ -92,6 +135,122 @@ func WithImagesDir(imagesDir string) OptFunc {
 	}
 }
 
+// WithKeepMasterKey saves the master key file also when it is split into parts.
+// Anyone having the master key file can decrypt the secret, bypassing the threshold.
+func WithKeepMasterKey(keep bool) OptFunc {
+	return func(e *Encrypter) error {
+		e.KeepMasterKey = keep
+
+		return nil
+	}
+}
+
+// WithVerifiable splits the key into parts that can be verified by their holders against the commitments,
+// saved along with the encrypted file.
+func WithVerifiable(verifiable bool) OptFunc {
+	return func(e *Encrypter) error {
+		e.Verifiable = verifiable
+
+		return nil
+	}
+}
+
+// WithKeyFormat sets the format of the partial key files: base64 (default), or mnemonic,
+// a list of words easier to write on paper.
+func WithKeyFormat(format string) OptFunc {
+	return func(e *Encrypter) error {
+		switch format {
+		case "", KeyFormatBase64, KeyFormatMnemonic:
+			e.KeyFormat = format
+		default:
+			return errors.Errorf("unknown key format '%s' [%s %s]", format, KeyFormatBase64, KeyFormatMnemonic)
+		}
+
+		return nil
+	}
+}
+
+// WithQRCodes writes every partial key also as a QR code image (NNN.qr.png) for paper backups,
+// and also as an SVG image (NNN.qr.svg) if svg is true.
+func WithQRCodes(enabled, svg bool) OptFunc {
+	return func(e *Encrypter) error {
+		e.QRCodes = enabled || svg
+		e.QRCodesSVG = svg
+
+		return nil
+	}
+}
+
+// WithArmor writes the encrypted file and the partial key files (in the base64 format) as ASCII armored blocks,
+// to paste them in emails, tickets or configuration files.
+func WithArmor(enabled bool) OptFunc {
+	return func(e *Encrypter) error {
+		e.Armor = enabled
+
+		return nil
+	}
+}
+
+// WithSteganographer sets the backend used to hide the partial keys into the images.
+func WithSteganographer(name string) OptFunc {
+	return func(e *Encrypter) error {
+		s, err := image.Backend(name)
+		if err != nil {
+			return err
+		}
+
+		e.Steganographer = s
+
+		return nil
+	}
+}
+
+// WithImagePINs sets the PINs of the holders of the images (one for every part),
+// needed to hide the partial keys with a backend using a key.
+func WithImagePINs(pins [][]byte) OptFunc {
+	return func(e *Encrypter) error {
+		for i, pin := range pins {
+			if len(pin) == 0 {
+				return errors.Errorf("empty PIN for partial key %03d", i+1)
+			}
+		}
+
+		e.ImagePINs = pins
+
+		return nil
+	}
+}
+
+// WithPartPassphrases encrypts every part with the passphrase of its holder (one for every part),
+// before writing it to the partial key file and hiding it into the image.
+func WithPartPassphrases(passphrases [][]byte) OptFunc {
+	return func(e *Encrypter) error {
+		for i, passphrase := range passphrases {
+			if len(passphrase) == 0 {
+				return errors.Errorf("empty passphrase for partial key %03d", i+1)
+			}
+		}
+
+		e.PartPassphrases = passphrases
+
+		return nil
+	}
+}
+
+// WithPassphrase derives the master key from the passphrase with Argon2id.
+// The salt and the parameters are stored in the header of the encrypted file.
+func WithPassphrase(passphrase []byte) OptFunc {
+	return func(e *Encrypter) error {
+		if len(passphrase) == 0 {
+			return errors.New("empty passphrase")
+		}
+
+		e.Passphrase = passphrase
+
+		return nil
+	}
+}
+
 func WithLogger(logger log.Logger) OptFunc {
 	return func(e *Encrypter) error {
 		e.Logger = logger
@@ -101,89 +260,513 @@ func WithLogger(logger log.Logger) OptFunc {
 }
 
 func (e *Encrypter) Encrypt(reader io.Reader, filename string) error {
+	return e.encrypt(reader, filename, sss.ContentFile)
+}
+
+// EncryptDir encrypts the directory as a tar archive, compressed with gzip if compress is set.
+// The archive is streamed while it is encrypted, and the output directory is excluded from it.
+func (e *Encrypter) EncryptDir(dir string, compress bool) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed reading directory '%s'", dir)
+	}
+
+	if !info.IsDir() {
+		return errors.Errorf("'%s' is not a directory", dir)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrap(err, "error getting absolute path for directory")
+	}
+
+	filename, contentType := filepath.Base(absDir)+".tar", sss.ContentTar
+	if compress {
+		filename, contentType = filename+".gz", sss.ContentTarGzip
+	}
+
+	reader, writer := io.Pipe()
+	// closing the reader stops the archiving if the encryption fails
+	defer reader.Close()
+
+	go func() {
+		_ = writer.CloseWithError(archive.Write(writer, absDir, compress, e.OutputDir))
+	}()
+
+	return e.encrypt(reader, filename, contentType)
+}
+
+func (e *Encrypter) encrypt(reader io.Reader, filename string, contentType sss.ContentType) error {
 	e.Logger.Print(fmt.Sprintf("🔒 Encrypting '%s'", filename))
 
-	masterKey, err := e.generateAndSaveMasterKey(filename)
+	masterKey, kdfParams, err := e.masterKey()
 	if err != nil {
-		return errors.Wrapf(err, "failed generating and saving master key '%s'", filename)
+		return err
 	}
 
-	if e.Parts <= 1 {
+	if e.Parts <= 1 && e.Passphrase == nil {
 		e.Logger.Print("No parts provided. Only the master-key will be generated.")
 	}
 
 	e.Logger.Debug("Generated master-key:", base64.StdEncoding.EncodeToString(masterKey))
 
-	err = e.encryptAndSaveMessage(masterKey, reader, filename)
-	if err != nil {
-		return errors.Wrapf(err, "failed encrypting and saving message '%s'", filename)
-	}
+	// the key is split before the encryption to store the share set ID in the header,
+	// and the parts are prepared to fail before writing anything if they cannot be saved
+	var (
+		commitments *sss.Commitments
+		setID       sss.ShareSetID
+		contents    [][]byte
+		images      []string
+	)
 
 	if e.Parts > 1 {
-		err = e.splitAndSaveKey(masterKey)
+		var parts []sss.Part
+
+		parts, commitments, err = e.splitKey(masterKey)
+		if err != nil {
+			return errors.Wrap(err, "failed splitting master key")
+		}
+
+		setID = parts[0].SetID
+
+		contents, images, err = e.prepareParts(parts)
+		if err != nil {
+			return errors.Wrap(err, "failed preparing partial keys")
+		}
+	}
+
+	// the master key is not saved when split, otherwise it would bypass the threshold,
+	// and it is not needed when it can be derived from the passphrase
+	if (e.Parts <= 1 && e.Passphrase == nil) || e.KeepMasterKey {
+		if e.Parts > 1 {
+			e.Logger.Print("⚠️  The master-key is saved: anyone having it can decrypt the secret without the partial keys!")
+		}
+
+		err = e.saveMasterKey(masterKey, filename)
 		if err != nil {
-			return errors.Wrap(err, "failed splitting and saving master key")
+			return errors.Wrapf(err, "failed saving master key '%s'", filename)
 		}
 	}
 
+	header := &sss.Header{
+		Cipher:        sss.CipherAES256GCMStream,
+		KDF:           kdfParams,
+		Filename:      filename,
+		ContentType:   contentType,
+		PlaintextSize: messageSize(reader),
+		ChunkSize:     sss.DefaultChunkSize,
+		Parts:         e.Parts,
+		Threshold:     e.Threshold,
+		ShareSetID:    setID,
+	}
+
+	err = e.encryptAndSaveMessage(masterKey, reader, header)
+	if err == nil && e.Parts > 1 {
+		err = e.saveCommitments(commitments, filepath.Join(e.OutputDir, filename+".commitments"))
+		if err == nil {
+			err = e.saveKeysIntoImages(contents, images)
+		}
+	}
+
+	if err != nil {
+		// without the partial keys the encrypted file could not be decrypted anymore
+		e.removeOutputs(filename)
+
+		return errors.Wrapf(err, "failed encrypting and saving message '%s'", filename)
+	}
+
 	e.Logger.Print("Encrypted files and keys saved to:", e.OutputDir)
 
 	return nil
 }
 
-func (e *Encrypter) generateAndSaveMasterKey(filename string) ([]byte, error) {
-	masterKey, err := sss.GenerateMasterKey()
+// removeOutputs removes the files written encrypting the message, after a failure.
+func (e *Encrypter) removeOutputs(filename string) {
+	base := filepath.Join(e.OutputDir, filename)
+
+	for _, output := range []string{base + ".enc", base + ".enc.checksum", base + ".checksum", base + ".enc.key", base + ".commitments"} {
+		if err := os.Remove(output); err == nil {
+			e.Logger.Debug("Removed file:", output)
+		}
+	}
+}
+
+// masterKey returns a new random master key, or the key derived from the passphrase
+// along with the KDF parameters to store in the header.
+func (e *Encrypter) masterKey() ([]byte, sss.KDFParams, error) {
+	if e.Passphrase == nil {
+		masterKey, err := sss.GenerateMasterKey()
+		if err != nil {
+			return nil, sss.KDFParams{}, errors.Wrap(err, "failed generating master key")
+		}
+
+		return masterKey, sss.KDFParams{KDF: sss.KDFNone}, nil
+	}
+
+	e.Logger.Print("🔑 Deriving master-key from passphrase")
+
+	params, err := sss.NewArgon2idParams()
+	if err != nil {
+		return nil, sss.KDFParams{}, err
+	}
+
+	masterKey, err := sss.DeriveKey(e.Passphrase, params)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed generating master key")
+		return nil, sss.KDFParams{}, errors.Wrap(err, "failed deriving master key")
 	}
 
+	return masterKey, params, nil
+}
+
+func (e *Encrypter) saveMasterKey(masterKey []byte, filename string) error {
 	encFilename := filepath.Join(e.OutputDir, filename+".enc")
 
-	err = file.WriteKey(e.Logger, masterKey, encFilename)
+	err := file.WriteKey(e.Logger, masterKey, encFilename)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed writing key file")
+		return errors.Wrap(err, "failed writing key file")
 	}
 
-	return masterKey, nil
+	return nil
 }
 
-func (e *Encrypter) encryptAndSaveMessage(masterKey []byte, reader io.Reader, filename string) error {
-	message, err := io.ReadAll(reader)
+func (e *Encrypter) encryptAndSaveMessage(
+	masterKey []byte,
+	reader io.Reader,
+	header *sss.Header,
+) error {
+	filename := header.Filename
+	encryptedFilename := filepath.Join(e.OutputDir, filename+".enc")
+
+	encryptedFile, err := os.Create(encryptedFilename)
+	if err != nil {
+		return errors.Wrapf(err, "failed creating file '%s'", encryptedFilename)
+	}
+	defer encryptedFile.Close()
+
+	// the message and the encrypted file are hashed while streaming to write their checksums
+	messageHash := sha256.New()
+	encryptedHash := sha256.New()
+
+	var out io.WriteCloser = nopCloser{io.MultiWriter(encryptedFile, encryptedHash)}
+
+	if e.Armor {
+		out, err = armor.Encode(io.MultiWriter(encryptedFile, encryptedHash), armor.TypeSecret, secretArmorHeaders(header))
+		if err != nil {
+			return err
+		}
+	}
+
+	size, err := encryptStream(header, masterKey, io.TeeReader(reader, messageHash), out)
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil && header.PlaintextSize != sss.UnknownSize && size != header.PlaintextSize {
+		err = errors.Errorf("message size changed while encrypting: expected %d, read %d", header.PlaintextSize, size)
+	}
+
+	if err == nil {
+		err = encryptedFile.Close()
+	}
+
 	if err != nil {
-		return errors.Wrap(err, "failed reading message")
+		_ = os.Remove(encryptedFilename)
+
+		return errors.Wrap(err, "failed writing encoded file")
 	}
 
-	err = file.WriteChecksum(e.Logger, message, filepath.Join(e.OutputDir, filename))
+	e.Logger.Debug("Created file:", encryptedFilename)
+
+	err = file.WriteHashChecksum(e.Logger, messageHash, filepath.Join(e.OutputDir, filename))
 	if err != nil {
 		return errors.Wrap(err, "failed writing checksum file of original message")
 	}
 
-	encryptedMessage, err := sss.Encrypt(masterKey, message)
+	err = file.WriteHashChecksum(e.Logger, encryptedHash, encryptedFilename)
 	if err != nil {
-		return errors.Wrap(err, "failed encrypting message")
+		return errors.Wrap(err, "failed writing checksum file")
 	}
 
-	encryptedFilename := filepath.Join(e.OutputDir, filename+".enc")
+	return nil
+}
 
-	err = file.WriteFile(e.Logger, encryptedMessage, encryptedFilename)
+// encryptStream writes the header followed by the encrypted content read from the reader,
+// returning the size of the encrypted message.
+func encryptStream(header *sss.Header, key []byte, reader io.Reader, writer io.Writer) (int64, error) {
+	headerBytes, err := header.MarshalBinary()
 	if err != nil {
-		return errors.Wrap(err, "failed writing encoded file")
+		return 0, errors.Wrap(err, "failed marshaling header")
 	}
 
-	err = file.WriteChecksum(e.Logger, encryptedMessage, encryptedFilename)
+	authenticatedData, err := header.AuthenticatedData()
 	if err != nil {
-		return errors.Wrap(err, "failed writing checksum file")
+		return 0, errors.Wrap(err, "failed marshaling header")
+	}
+
+	if _, err = writer.Write(headerBytes); err != nil {
+		return 0, errors.Wrap(err, "failed writing header")
+	}
+
+	stream, err := sss.EncryptStream(key, authenticatedData, int(header.ChunkSize), writer)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed creating encryption stream")
+	}
+
+	size, err := io.Copy(stream, reader)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed encrypting message")
 	}
 
+	if err = stream.Close(); err != nil {
+		return 0, errors.Wrap(err, "failed encrypting message")
+	}
+
+	return size, nil
+}
+
+// messageSize returns the size of the message to encrypt if it is known in advance.
+func messageSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+
+	return sss.UnknownSize
+}
+
+// SplitAndSaveKey splits the key into the configured parts, saving them as partial key files
+// and hiding them into the images, if available. Any secret can be split, not only a master key.
+func (e *Encrypter) SplitAndSaveKey(masterKey []byte) error {
+	if e.Parts <= 1 {
+		return errors.Errorf("at least 2 parts are needed to split a key, got %d", e.Parts)
+	}
+
+	parts, commitments, err := e.splitKey(masterKey)
+	if err != nil {
+		return err
+	}
+
+	err = e.saveCommitments(commitments, filepath.Join(e.OutputDir, "secret.commitments"))
+	if err != nil {
+		return err
+	}
+
+	return e.saveParts(parts)
+}
+
+// Reshare recovers the key from the parts and splits it again into a new share set with the configured
+// parts and threshold. If the encrypted file is provided its header is updated with the new share set,
+// so that the old parts cannot be used anymore to decrypt it.
+func (e *Encrypter) Reshare(oldParts []sss.Part, encryptedFilename string) error {
+	if e.Parts <= 1 {
+		return errors.Errorf("at least 2 parts are needed to reshare a key, got %d", e.Parts)
+	}
+
+	e.Logger.Print(fmt.Sprintf("Resharing key into %d parts (threshold: %d)", e.Parts, e.Threshold))
+
+	var (
+		parts       []sss.Part
+		commitments *sss.Commitments
+		err         error
+	)
+
+	if e.Verifiable {
+		parts, commitments, err = sss.ReshareVerifiable(oldParts, e.Parts, e.Threshold)
+	} else {
+		parts, err = sss.Reshare(oldParts, e.Parts, e.Threshold)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed resharing key")
+	}
+
+	e.Logger.Print("New share set:", parts[0].SetID)
+
+	// the new parts and commitments are saved before updating the encrypted file: if they cannot be saved
+	// the encrypted file is left untouched, and the old parts can still decrypt it
+	err = e.saveParts(parts)
+	if err != nil {
+		return errors.Wrap(err, "failed saving partial keys")
+	}
+
+	// the commitments are saved along with the encrypted file, replacing the old ones
+	commitmentsFilename := filepath.Join(e.OutputDir, "secret.commitments")
+	if encryptedFilename != "" {
+		commitmentsFilename = strings.TrimSuffix(encryptedFilename, ".enc") + ".commitments"
+	}
+
+	err = e.saveCommitments(commitments, commitmentsFilename)
+	if err != nil {
+		return err
+	}
+
+	// the commitments of the old parts cannot verify the new (not verifiable) ones
+	if commitments == nil {
+		err = os.Remove(commitmentsFilename)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed removing old commitments file '%s'", commitmentsFilename)
+		}
+	}
+
+	if encryptedFilename == "" {
+		return nil
+	}
+
+	err = e.updateShareScheme(encryptedFilename, parts[0])
+	if err != nil {
+		return errors.Wrapf(err, "failed updating share set of '%s'", encryptedFilename)
+	}
+
+	return nil
+}
+
+// rewriteShareScheme copies the encrypted file updating the share scheme in its header.
+// An armored file is written armored again, with the updated headers.
+func rewriteShareScheme(r io.Reader, w io.Writer, part sss.Part) error {
+	update := func(h *sss.Header) {
+		h.Parts, h.Threshold, h.ShareSetID = part.Parts, part.Threshold, part.SetID
+	}
+
+	reader := bufio.NewReader(r)
+
+	// error ignored: a short content will not be armored
+	prefix, _ := reader.Peek(64)
+	if !armor.IsArmored(prefix) {
+		return sss.RewriteHeader(reader, w, update)
+	}
+
+	block, err := armor.Decode(reader)
+	if err != nil {
+		return errors.Wrap(err, "failed reading armored file")
+	}
+
+	content, err := io.ReadAll(block.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed reading armored file")
+	}
+
+	header, err := sss.ReadHeader(bytes.NewReader(content))
+	if err != nil {
+		return errors.Wrap(err, "failed reading header")
+	}
+
+	update(header)
+
+	out, err := armor.Encode(w, armor.TypeSecret, secretArmorHeaders(header))
+	if err != nil {
+		return err
+	}
+
+	if err := sss.RewriteHeader(bytes.NewReader(content), out, update); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// nopCloser adds a no-op Close method to a writer.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
 	return nil
 }
 
-func (e *Encrypter) splitAndSaveKey(masterKey []byte) error {
+// secretArmorHeaders returns the informational headers of the armored encrypted file.
+func secretArmorHeaders(header *sss.Header) map[string]string {
+	headers := map[string]string{"Filename": header.Filename}
+
+	if header.Parts > 1 {
+		headers["Parts"] = strconv.Itoa(int(header.Parts))
+		headers["Threshold"] = strconv.Itoa(int(header.Threshold))
+	}
+
+	if !header.ShareSetID.IsZero() {
+		headers["Share-Set"] = header.ShareSetID.String()
+	}
+
+	return headers
+}
+
+// partArmorHeaders returns the informational headers of the armored partial key file.
+func partArmorHeaders(content []byte) map[string]string {
+	part, err := sss.NewPartFromContent(content)
+	if err != nil {
+		// the part is protected by the passphrase of its holder
+		return map[string]string{"Protected": "passphrase"}
+	}
+
+	headers := map[string]string{
+		"Tag":       strconv.Itoa(int(part.Tag)),
+		"Parts":     strconv.Itoa(int(part.Parts)),
+		"Threshold": strconv.Itoa(int(part.Threshold)),
+	}
+
+	if !part.SetID.IsZero() {
+		headers["Share-Set"] = part.SetID.String()
+	}
+
+	return headers
+}
+
+// updateShareScheme rewrites the header of the encrypted file with the share scheme of the part.
+func (e *Encrypter) updateShareScheme(encryptedFilename string, part sss.Part) error {
+	encryptedFile, err := os.Open(encryptedFilename)
+	if err != nil {
+		return errors.Wrapf(err, "failed opening file '%s'", encryptedFilename)
+	}
+	defer encryptedFile.Close()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(encryptedFilename), ".stego-*")
+	if err != nil {
+		return errors.Wrap(err, "failed creating temporary file")
+	}
+
+	encryptedHash := sha256.New()
+
+	err = rewriteShareScheme(encryptedFile, io.MultiWriter(tmpFile, encryptedHash), part)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), encryptedFilename)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+
+		return err
+	}
+
+	e.Logger.Debug("Updated file:", encryptedFilename)
+
+	return file.WriteHashChecksum(e.Logger, encryptedHash, encryptedFilename)
+}
+
+// splitKey splits the key into parts, returning also the commitments if the parts are verifiable.
+func (e *Encrypter) splitKey(masterKey []byte) ([]sss.Part, *sss.Commitments, error) {
 	e.Logger.Print(fmt.Sprintf("Splitting key into %d parts (threshold: %d)", e.Parts, e.Threshold))
 
-	parts, err := sss.Split(masterKey, e.Parts, e.Threshold)
+	var (
+		parts       []sss.Part
+		commitments *sss.Commitments
+		err         error
+	)
+
+	if e.Verifiable {
+		parts, commitments, err = sss.SplitVerifiable(masterKey, e.Parts, e.Threshold)
+	} else {
+		parts, err = sss.Split(masterKey, e.Parts, e.Threshold)
+	}
+
 	if err != nil {
-		return errors.Wrap(err, "failed splitting masterkey")
+		return nil, nil, errors.Wrap(err, "failed splitting masterkey")
 	}
 
 	e.Logger.Debug("Partial keys:")
@@ -192,12 +775,37 @@ func (e *Encrypter) splitAndSaveKey(masterKey []byte) error {
 		e.Logger.Debug(fmt.Sprintf("%d) %s", i+1, p.Base64()))
 	}
 
-	images, err := e.getImages(len(parts))
+	return parts, commitments, nil
+}
+
+// saveCommitments saves the commitments of the verifiable parts, if any.
+func (e *Encrypter) saveCommitments(commitments *sss.Commitments, filename string) error {
+	if commitments == nil {
+		return nil
+	}
+
+	content, err := commitments.MarshalBinary()
 	if err != nil {
-		e.Logger.Print("failed getting images")
+		return errors.Wrap(err, "failed marshaling commitments")
 	}
 
-	err = e.saveKeysIntoImages(parts, images)
+	err = file.WriteFile(e.Logger, []byte(base64.StdEncoding.EncodeToString(content)), filename)
+	if err != nil {
+		return errors.Wrap(err, "failed writing commitments file")
+	}
+
+	e.Logger.Print("Commitments to verify the partial keys saved to:", filename)
+
+	return nil
+}
+
+func (e *Encrypter) saveParts(parts []sss.Part) error {
+	contents, images, err := e.prepareParts(parts)
+	if err != nil {
+		return err
+	}
+
+	err = e.saveKeysIntoImages(contents, images)
 	if err != nil {
 		return errors.Wrap(err, "failed saving keys into images")
 	}
@@ -205,7 +813,37 @@ func (e *Encrypter) splitAndSaveKey(masterKey []byte) error {
 	return nil
 }
 
-func (e *Encrypter) getImages(count int) ([]string, error) {
+// prepareParts returns the contents of the parts and the images where to hide them,
+// checking that they can be saved (passphrases and PINs of the holders) before writing anything.
+func (e *Encrypter) prepareParts(parts []sss.Part) ([][]byte, []string, error) {
+	contents := make([][]byte, 0, len(parts))
+
+	for i, part := range parts {
+		content, err := e.partContent(i, part)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		contents = append(contents, content)
+	}
+
+	images, err := e.getImages(len(contents), len(contents[0]))
+	if err != nil {
+		e.Logger.Print("failed getting images")
+	}
+
+	for i := range images {
+		if _, err := e.steganographer(i); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return contents, images, nil
+}
+
+// getImages returns count images big enough to hide a secret of secretSize bytes,
+// reusing the available ones if there are not enough.
+func (e *Encrypter) getImages(count, secretSize int) ([]string, error) {
 	files, err := os.ReadDir(e.ImagesDir)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed reading images folder '%s'", e.ImagesDir)
@@ -216,7 +854,22 @@ func (e *Encrypter) getImages(count int) ([]string, error) {
 	for _, file := range files {
 		switch filepath.Ext(file.Name()) {
 		case ".jpg", ".jpeg", ".png":
-			images = append(images, filepath.Join(e.ImagesDir, file.Name()))
+			imageFile := filepath.Join(e.ImagesDir, file.Name())
+
+			available, err := image.CapacityFromFile(e.Steganographer, imageFile)
+			if err != nil {
+				e.Logger.Debug(fmt.Sprintf("Skipping image '%s': %s", imageFile, err))
+
+				continue
+			}
+
+			if available < secretSize {
+				e.Logger.Debug("Skipping", &image.CapacityError{Image: imageFile, Required: secretSize, Available: available})
+
+				continue
+			}
+
+			images = append(images, imageFile)
 		}
 
 		// if we have sufficient amount of images, we're done, early return
@@ -226,7 +879,7 @@ func (e *Encrypter) getImages(count int) ([]string, error) {
 	}
 
 	if len(images) == 0 {
-		return nil, errors.Errorf("no image files in %s dir: run 'stego images' to get some random pics", e.ImagesDir)
+		return nil, errors.Errorf("no image files big enough in %s dir: run 'stego images' to get some random pics", e.ImagesDir)
 	}
 
 	// if we don't have sufficient amount of images, fill up with images we have
@@ -239,41 +892,145 @@ func (e *Encrypter) getImages(count int) ([]string, error) {
 	return images, nil
 }
 
-func (e *Encrypter) saveKeysIntoImages(parts []sss.Part, images []string) error {
+// partContent returns the content of the i-th part to save, encrypted with the passphrase of its holder if provided.
+func (e *Encrypter) partContent(i int, part sss.Part) ([]byte, error) {
+	if e.PartPassphrases == nil {
+		return part.Bytes(), nil
+	}
+
+	if i >= len(e.PartPassphrases) {
+		return nil, errors.Errorf("missing passphrase for partial key %03d", i+1)
+	}
+
+	e.Logger.Debug(fmt.Sprintf("Encrypting partial key %03d with the passphrase of its holder", i+1))
+
+	content, err := sss.WrapPart(part, e.PartPassphrases[i])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed encrypting partial key %03d", i+1)
+	}
+
+	return content, nil
+}
+
+// writePartialKey writes the .key file of a partial key, in the configured format.
+func (e *Encrypter) writePartialKey(content []byte, filename string) error {
+	if e.KeyFormat == KeyFormatMnemonic {
+		return file.WriteMnemonicKey(e.Logger, content, filename)
+	}
+
+	if e.Armor {
+		return file.WriteArmoredKey(e.Logger, content, filename, partArmorHeaders(content))
+	}
+
+	return file.WriteKey(e.Logger, content, filename)
+}
+
+func (e *Encrypter) saveKeysIntoImages(contents [][]byte, images []string) error {
 	if len(images) == 0 {
 		e.Logger.Print("No images found.")
 	}
 
-	for i, part := range parts {
-		partialKeyFilename := filepath.Join(e.OutputDir, fmt.Sprintf("%03d", i+1))
+	for i, content := range contents {
+		err := e.saveKeyIntoImage(i, content, images)
+		if err != nil {
+			// the partial keys already written are useless without the others
+			for j := 0; j <= i; j++ {
+				e.removePartFiles(j, len(images) > 0)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveKeyIntoImage writes the i-th partial key file, hiding it into its image if available.
+func (e *Encrypter) saveKeyIntoImage(i int, content []byte, images []string) error {
+	partialKeyFilename := filepath.Join(e.OutputDir, fmt.Sprintf("%03d", i+1))
+
+	e.Logger.Print(fmt.Sprintf("🔑 Writing partial key %03d", i+1))
+
+	// write .key file
+	err := e.writePartialKey(content, partialKeyFilename)
+	if err != nil {
+		return errors.Wrapf(err, "failed writing key file '%s'", partialKeyFilename)
+	}
 
-		e.Logger.Print(fmt.Sprintf("🔑 Writing partial key %03d", i+1))
+	if e.QRCodes {
+		e.Logger.Debug(fmt.Sprintf("Writing partial key %03d as QR code", i+1))
 
-		// write .key file
-		err := file.WriteKey(e.Logger, part.Bytes(), partialKeyFilename)
+		err = file.WriteQRKey(e.Logger, content, partialKeyFilename, e.QRCodesSVG)
 		if err != nil {
-			return errors.Wrapf(err, "failed writing key file '%s'", partialKeyFilename)
+			return errors.Wrapf(err, "failed writing QR code of partial key %03d", i+1)
 		}
+	}
 
-		// if the images are available hide the key inside them
-		if len(images) > 0 {
-			imageOutName := partialKeyFilename + ".png"
+	// if the images are available hide the key inside them
+	if len(images) == 0 {
+		return nil
+	}
 
-			e.Logger.Debug(fmt.Sprintf("Writing partial key %03d into image", i+1))
+	imageOutName := partialKeyFilename + image.Extension(e.Steganographer)
 
-			err := image.EncodeSecretFromFile(part.Bytes(), images[i], imageOutName)
-			if err != nil {
-				return errors.Wrapf(err, "failed encoding secret into image file '%s'", imageOutName)
-			}
+	e.Logger.Debug(fmt.Sprintf("Writing partial key %03d into image", i+1))
 
-			e.Logger.Debug(fmt.Sprintf("Writing partial key %03d checksum", i+1))
+	steganographer, err := e.steganographer(i)
+	if err != nil {
+		return err
+	}
 
-			err = file.WriteFileChecksum(e.Logger, imageOutName)
-			if err != nil {
-				return errors.Wrapf(err, "failed writing checksum file '%s'", imageOutName)
-			}
-		}
+	err = image.EncodeSecretFromFileWith(steganographer, content, images[i], imageOutName)
+	if err != nil {
+		return errors.Wrapf(err, "failed encoding secret into image file '%s'", imageOutName)
+	}
+
+	e.Logger.Debug(fmt.Sprintf("Writing partial key %03d checksum", i+1))
+
+	err = file.WriteFileChecksum(e.Logger, imageOutName)
+	if err != nil {
+		return errors.Wrapf(err, "failed writing checksum file '%s'", imageOutName)
 	}
 
 	return nil
 }
+
+// removePartFiles removes the files written for the i-th partial key, after a failure.
+func (e *Encrypter) removePartFiles(i int, withImage bool) {
+	partialKeyFilename := filepath.Join(e.OutputDir, fmt.Sprintf("%03d", i+1))
+	outputs := []string{partialKeyFilename + ".key"}
+
+	if e.QRCodes {
+		outputs = append(outputs, partialKeyFilename+file.QRExtension)
+	}
+
+	if e.QRCodesSVG {
+		outputs = append(outputs, partialKeyFilename+file.QRSVGExtension)
+	}
+
+	if withImage {
+		imageOutName := partialKeyFilename + image.Extension(e.Steganographer)
+		outputs = append(outputs, imageOutName, imageOutName+".checksum")
+	}
+
+	for _, output := range outputs {
+		if err := os.Remove(output); err == nil {
+			e.Logger.Debug("Removed file:", output)
+		}
+	}
+}
+
+// steganographer returns the backend to hide the i-th partial key, using the PIN of its holder if needed.
+func (e *Encrypter) steganographer(i int) (image.Steganographer, error) {
+	keyed, ok := e.Steganographer.(image.KeyedSteganographer)
+	if !ok {
+		return e.Steganographer, nil
+	}
+
+	if i >= len(e.ImagePINs) {
+		return nil, errors.Errorf("missing PIN for partial key %03d: the '%s' backend needs a PIN for every image",
+			i+1, e.Steganographer.Name())
+	}
+
+	return keyed.WithKey(e.ImagePINs[i]), nil
+}
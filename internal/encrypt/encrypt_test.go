@@ -2,6 +2,8 @@ package encrypt_test
 
 import (
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"io/fs"
 	"os"
@@ -9,8 +11,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/enrichman/stegosecrets/internal/decrypt"
 	"github.com/enrichman/stegosecrets/internal/encrypt"
 	"github.com/enrichman/stegosecrets/internal/log"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -126,7 +130,7 @@ func TestEncrypt(t *testing.T) {
 
 	assert.DirExists(t, tmpDir)
 	assert.FileExists(t, tmpDir+"/secret.enc")
-	assert.FileExists(t, tmpDir+"/secret.enc.key")
+	assert.NoFileExists(t, tmpDir+"/secret.enc.key")
 	assert.FileExists(t, tmpDir+"/secret.checksum")
 	assert.FileExists(t, tmpDir+"/secret.enc.checksum")
 
@@ -139,3 +143,203 @@ func TestEncrypt(t *testing.T) {
 	err = os.RemoveAll(tmpDir)
 	require.NoError(t, err)
 }
+
+func TestEncrypt_KeepMasterKey(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(5, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithKeepMasterKey(true),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.NoError(t, err)
+
+	assert.FileExists(t, tmpDir+"/secret.enc")
+	assert.FileExists(t, tmpDir+"/secret.enc.key")
+	assert.FileExists(t, tmpDir+"/001.key")
+}
+
+func TestEncrypt_SkipSmallImages(t *testing.T) {
+	imagesDir := t.TempDir()
+	tmpDir := t.TempDir()
+
+	writePNG(t, filepath.Join(imagesDir, "small.png"), 4, 4)
+	writePNG(t, filepath.Join(imagesDir, "big.png"), 64, 64)
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir(imagesDir),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		imageFile := fmt.Sprintf("%s/%03d.png", tmpDir, i)
+		assert.FileExists(t, imageFile)
+
+		// all the shares are hidden in the only image big enough
+		config, err := decodePNGConfig(imageFile)
+		require.NoError(t, err)
+		assert.Equal(t, 64, config.Width)
+	}
+}
+
+func writePNG(t *testing.T, filename string, width, height int) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height))))
+}
+
+func decodePNGConfig(filename string) (image.Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	return png.DecodeConfig(f)
+}
+
+func TestEncrypt_Steganographer(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_, err := encrypt.NewEncrypter(encrypt.WithSteganographer("unknown"))
+	require.Error(t, err)
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithSteganographer("dct"),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		imageFile := fmt.Sprintf("%s/%03d.jpg", tmpDir, i)
+		assert.FileExists(t, imageFile)
+		assert.FileExists(t, imageFile+".checksum")
+
+		part, err := decrypt.ReadPartialKeyImageFile(imageFile)
+		require.NoError(t, err)
+
+		key, err := decrypt.ReadPartialKeyFile(fmt.Sprintf("%s/%03d.key", tmpDir, i))
+		require.NoError(t, err)
+		assert.Equal(t, key, part)
+	}
+}
+
+func TestReshare_FailedSaveKeepsOldParts(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := log.NewSimpleLogger(io.Discard, log.None)
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir(t.TempDir()),
+		encrypt.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, encrypter.Encrypt(strings.NewReader("hello world!"), "secret"))
+
+	oldParts := []sss.Part{}
+
+	for i := 1; i <= 2; i++ {
+		part, err := decrypt.ReadPartialKeyFile(fmt.Sprintf("%s/%03d.key", tmpDir, i))
+		require.NoError(t, err)
+
+		oldParts = append(oldParts, part)
+	}
+
+	// the keyed backend without PINs fails saving the new parts into the images
+	resharer, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(filepath.Join(tmpDir, "new")),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithSteganographer("lsb-scatter"),
+		encrypt.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	err = resharer.Reshare(oldParts, filepath.Join(tmpDir, "secret.enc"))
+	require.Error(t, err)
+
+	// the encrypted file still accepts the old parts
+	decrypter, err := decrypt.NewDecrypter(
+		decrypt.WithPartialKeyFile(tmpDir+"/001.key"),
+		decrypt.WithPartialKeyFile(tmpDir+"/002.key"),
+	)
+	require.NoError(t, err)
+
+	decrypter.Logger = logger
+
+	require.NoError(t, decrypter.Decrypt(filepath.Join(tmpDir, "secret.enc")))
+
+	decrypted, err := os.ReadFile(filepath.Join(tmpDir, "secret"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello world!"), decrypted)
+}
+
+func TestEncrypt_InvalidPartsWriteNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// the keyed backend needs a PIN for every image
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithSteganographer("lsb-scatter"),
+		encrypt.WithImagePINs([][]byte{[]byte("1234")}),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.ErrorContains(t, err, "missing PIN for partial key 002")
+
+	files, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestEncrypt_FailedSaveRemovesOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// the second partial key cannot be written
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "002.key"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "002.key", "file"), []byte("keep"), 0o600))
+
+	encrypter, err := encrypt.NewEncrypter(
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.Error(t, err)
+
+	// the encrypted file cannot be left without its partial keys
+	files, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "002.key", files[0].Name())
+}
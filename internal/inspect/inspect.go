@@ -0,0 +1,293 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/enrichman/stegosecrets/internal/decrypt"
+	"github.com/enrichman/stegosecrets/pkg/armor"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/image"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/pkg/errors"
+)
+
+type FileType string
+
+const (
+	TypeKey       FileType = "key"
+	TypeImage     FileType = "image"
+	TypeQR        FileType = "qr"
+	TypeEncrypted FileType = "encrypted"
+)
+
+// Report describes the content of a partial key, an image or an encrypted file.
+type Report struct {
+	File   string      `json:"file"`
+	Type   FileType    `json:"type"`
+	Part   *PartInfo   `json:"part,omitempty"`
+	Header *HeaderInfo `json:"header,omitempty"`
+}
+
+type PartInfo struct {
+	// Protected is true if the part is encrypted with the passphrase of its holder: the other fields are unknown
+	Protected bool   `json:"protected,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Parts     uint8  `json:"parts"`
+	Threshold uint8  `json:"threshold"`
+	Tag       uint8  `json:"tag"`
+	SetID     string `json:"shareSetId,omitempty"`
+	Size      int    `json:"size"`
+	// Backend is the steganography backend that hid the part in the image
+	Backend string `json:"backend,omitempty"`
+}
+
+type HeaderInfo struct {
+	// Legacy is true for files created by older releases, without a header
+	Legacy        bool   `json:"legacy"`
+	Version       byte   `json:"version,omitempty"`
+	Cipher        string `json:"cipher,omitempty"`
+	KDF           string `json:"kdf,omitempty"`
+	Filename      string `json:"filename,omitempty"`
+	Content       string `json:"content,omitempty"`
+	PlaintextSize *int64 `json:"plaintextSize,omitempty"`
+	ChunkSize     uint32 `json:"chunkSize,omitempty"`
+	Parts         uint8  `json:"parts,omitempty"`
+	Threshold     uint8  `json:"threshold,omitempty"`
+	SetID         string `json:"shareSetId,omitempty"`
+}
+
+// File inspects the file, detecting if it is an encrypted file, an image or a partial key.
+func File(filename string) (*Report, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+
+	// error ignored: a short content will not match any magic
+	prefix, _ := reader.Peek(64)
+
+	switch {
+	case armor.IsArmored(prefix):
+		return inspectArmored(filename, reader)
+	case sss.HasHeader(prefix):
+		return inspectEncrypted(filename, reader)
+	case isImage(prefix) && strings.HasSuffix(filename, file.QRExtension):
+		return inspectQR(filename)
+	case isImage(prefix):
+		return inspectImage(filename)
+	case filepath.Ext(filename) == ".enc":
+		return &Report{File: filename, Type: TypeEncrypted, Header: &HeaderInfo{Legacy: true}}, nil
+	default:
+		return inspectKey(filename)
+	}
+}
+
+// isImage checks the magic bytes of the supported image formats (PNG and JPEG).
+func isImage(prefix []byte) bool {
+	return bytes.HasPrefix(prefix, []byte("\x89PNG")) || bytes.HasPrefix(prefix, []byte("\xff\xd8\xff"))
+}
+
+func inspectEncrypted(filename string, reader io.Reader) (*Report, error) {
+	header, err := sss.ReadHeader(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading header of '%s'", filename)
+	}
+
+	info := &HeaderInfo{
+		Version:   header.Version,
+		Cipher:    header.Cipher.String(),
+		KDF:       header.KDF.KDF.String(),
+		Filename:  header.Filename,
+		Content:   header.ContentType.String(),
+		ChunkSize: header.ChunkSize,
+		Parts:     header.Parts,
+		Threshold: header.Threshold,
+	}
+
+	if !header.ShareSetID.IsZero() {
+		info.SetID = header.ShareSetID.String()
+	}
+
+	if header.PlaintextSize != sss.UnknownSize {
+		info.PlaintextSize = &header.PlaintextSize
+	}
+
+	return &Report{File: filename, Type: TypeEncrypted, Header: info}, nil
+}
+
+func inspectImage(filename string) (*Report, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer f.Close()
+
+	content, backend, err := image.DecodeSecretBackend(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading partial key from image '%s'", filename)
+	}
+
+	info := &PartInfo{Protected: true}
+
+	if !sss.IsWrappedPart(content) {
+		part, err := sss.NewPartFromContent(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading partial key from image '%s'", filename)
+		}
+
+		info = newPartInfo(part)
+	}
+
+	info.Backend = backend.Name()
+
+	return &Report{File: filename, Type: TypeImage, Part: info}, nil
+}
+
+// inspectArmored inspects the armored encrypted files and partial keys.
+func inspectArmored(filename string, reader io.Reader) (*Report, error) {
+	block, err := armor.Decode(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading armored file '%s'", filename)
+	}
+
+	switch block.Type {
+	case armor.TypeSecret:
+		return inspectEncrypted(filename, block.Body)
+	case armor.TypePartialKey:
+		return inspectKey(filename)
+	default:
+		return nil, errors.Errorf("unknown armored block '%s' of '%s'", block.Type, filename)
+	}
+}
+
+func inspectQR(filename string) (*Report, error) {
+	part, err := decrypt.ReadPartialKeyQRFile(filename)
+	if errors.Is(err, sss.ErrWrappedPart) {
+		return &Report{File: filename, Type: TypeQR, Part: &PartInfo{Protected: true}}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{File: filename, Type: TypeQR, Part: newPartInfo(part)}, nil
+}
+
+func inspectKey(filename string) (*Report, error) {
+	part, err := decrypt.ReadPartialKeyFile(filename)
+	if errors.Is(err, sss.ErrWrappedPart) {
+		return &Report{File: filename, Type: TypeKey, Part: &PartInfo{Protected: true}}, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown file type '%s'", filename)
+	}
+
+	return &Report{File: filename, Type: TypeKey, Part: newPartInfo(part)}, nil
+}
+
+func newPartInfo(part sss.Part) *PartInfo {
+	info := &PartInfo{
+		Version:   partVersion(part.Version),
+		Parts:     part.Parts,
+		Threshold: part.Threshold,
+		Tag:       part.Tag,
+		Size:      len(part.Content),
+	}
+
+	if !part.SetID.IsZero() {
+		info.SetID = part.SetID.String()
+	}
+
+	return info
+}
+
+// partVersion returns the printable version of the part (stored as an ASCII character).
+func partVersion(version byte) string {
+	if version >= '0' && version <= '9' {
+		return string(version)
+	}
+
+	return fmt.Sprintf("%d", version)
+}
+
+// String returns the human readable representation of the report.
+func (r *Report) String() string {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, "File:           %s\n", r.File)
+
+	if r.Part != nil && r.Part.Protected {
+		fmt.Fprintf(out, "Type:           partial key (%s)\n", r.Type)
+		fmt.Fprintf(out, "Protected:      encrypted with the passphrase of the holder\n")
+
+		if r.Part.Backend != "" {
+			fmt.Fprintf(out, "Steganography:  %s\n", r.Part.Backend)
+		}
+
+		return out.String()
+	}
+
+	if r.Part != nil {
+		fmt.Fprintf(out, "Type:           partial key (%s)\n", r.Type)
+		fmt.Fprintf(out, "Version:        %s\n", r.Part.Version)
+		fmt.Fprintf(out, "Parts:          %d\n", r.Part.Parts)
+		fmt.Fprintf(out, "Threshold:      %d\n", r.Part.Threshold)
+		fmt.Fprintf(out, "Tag:            %d\n", r.Part.Tag)
+
+		if r.Part.SetID != "" {
+			fmt.Fprintf(out, "Share set:      %s\n", r.Part.SetID)
+		}
+
+		fmt.Fprintf(out, "Size:           %d bytes\n", r.Part.Size)
+
+		if r.Part.Backend != "" {
+			fmt.Fprintf(out, "Steganography:  %s\n", r.Part.Backend)
+		}
+	}
+
+	if r.Header != nil {
+		fmt.Fprintf(out, "Type:           %s file\n", r.Type)
+
+		if r.Header.Legacy {
+			fmt.Fprintf(out, "Format:         legacy (created by an older release, no header)\n")
+
+			return out.String()
+		}
+
+		fmt.Fprintf(out, "Format version: %d\n", r.Header.Version)
+		fmt.Fprintf(out, "Cipher:         %s\n", r.Header.Cipher)
+		fmt.Fprintf(out, "KDF:            %s\n", r.Header.KDF)
+		fmt.Fprintf(out, "Filename:       %s\n", r.Header.Filename)
+
+		if r.Header.Content != "" && r.Header.Content != sss.ContentFile.String() {
+			fmt.Fprintf(out, "Content:        directory (%s archive)\n", r.Header.Content)
+		}
+
+		if r.Header.PlaintextSize != nil {
+			fmt.Fprintf(out, "Size:           %d bytes\n", *r.Header.PlaintextSize)
+		} else {
+			fmt.Fprintf(out, "Size:           unknown\n")
+		}
+
+		if r.Header.Parts > 1 {
+			fmt.Fprintf(out, "Parts:          %d\n", r.Header.Parts)
+			fmt.Fprintf(out, "Threshold:      %d\n", r.Header.Threshold)
+		}
+
+		if r.Header.SetID != "" {
+			fmt.Fprintf(out, "Share set:      %s\n", r.Header.SetID)
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,72 @@
+package inspect_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/encrypt"
+	"github.com/enrichman/stegosecrets/internal/inspect"
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAssetsDir = "../../test/assets/p5t3/"
+
+func TestFile_Key(t *testing.T) {
+	report, err := inspect.File(testAssetsDir + "001.key")
+	require.NoError(t, err)
+
+	assert.Equal(t, inspect.TypeKey, report.Type)
+	assert.Equal(t, &inspect.PartInfo{Version: "1", Parts: 5, Threshold: 3, Tag: 40, Size: 32}, report.Part)
+	assert.Nil(t, report.Header)
+}
+
+func TestFile_Image(t *testing.T) {
+	report, err := inspect.File(testAssetsDir + "001.jpg")
+	require.NoError(t, err)
+
+	assert.Equal(t, inspect.TypeImage, report.Type)
+	assert.Equal(t, &inspect.PartInfo{Version: "1", Parts: 5, Threshold: 3, Tag: 40, Size: 32, Backend: "lsb"}, report.Part)
+}
+
+func TestFile_Encrypted(t *testing.T) {
+	t.Run("legacy", func(t *testing.T) {
+		report, err := inspect.File(testAssetsDir + "secret.enc")
+		require.NoError(t, err)
+
+		assert.Equal(t, inspect.TypeEncrypted, report.Type)
+		assert.True(t, report.Header.Legacy)
+	})
+
+	t.Run("with header", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		encrypter, err := encrypt.NewEncrypter(
+			encrypt.WithPartsAndThreshold(5, 3),
+			encrypt.WithOutputDir(tmpDir),
+			encrypt.WithImagesDir(testAssetsDir),
+			encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+		)
+		require.NoError(t, err)
+
+		err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+		require.NoError(t, err)
+
+		report, err := inspect.File(tmpDir + "/secret.enc")
+		require.NoError(t, err)
+
+		assert.Equal(t, inspect.TypeEncrypted, report.Type)
+		assert.False(t, report.Header.Legacy)
+		assert.Equal(t, "secret", report.Header.Filename)
+		assert.Equal(t, int64(12), *report.Header.PlaintextSize)
+		assert.Equal(t, uint8(5), report.Header.Parts)
+		assert.Equal(t, uint8(3), report.Header.Threshold)
+	})
+}
+
+func TestFile_Unknown(t *testing.T) {
+	_, err := inspect.File(testAssetsDir + "secret.checksum")
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+// Package sheet renders the partial keys into printable sheets, for the paper backups kept by the holders.
+// The sheets are self-contained HTML pages (the QR code is embedded), generated offline.
+package sheet
+
+import (
+	_ "embed" // the template is embedded
+	"encoding/base64"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/enrichman/stegosecrets/pkg/file"
+	"github.com/enrichman/stegosecrets/pkg/mnemonic"
+	"github.com/enrichman/stegosecrets/pkg/qr"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/pkg/errors"
+)
+
+// Extension is the extension of the sheets, replacing the .key extension of the partial key file.
+const Extension = ".sheet.html"
+
+//go:embed sheet.html
+var sheetTemplate string
+
+var tmpl = template.Must(template.New("sheet").Parse(sheetTemplate))
+
+// Sheet is the printable sheet of a partial key.
+type Sheet struct {
+	// KeyFile is the name of the partial key file
+	KeyFile string
+	// SecretName is the name of the encrypted secret, and EncryptedFile its file (if known)
+	SecretName    string
+	EncryptedFile string
+	// Created is the date of creation of the partial key file
+	Created time.Time
+
+	// Part is the partial key, nil if it is protected by the passphrase of its holder
+	Part     *sss.Part
+	Base64   string
+	Mnemonic []string
+	// QRCode is the data URI of the PNG image of the QR code
+	QRCode template.URL
+}
+
+// NewFromKeyFile creates the sheet of the partial key file. The encrypted file, if not empty,
+// is read to get the name of the secret.
+func NewFromKeyFile(filename, encryptedFile string) (*Sheet, error) {
+	content, err := file.ReadKey(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading info of file '%s'", filename)
+	}
+
+	s := &Sheet{
+		KeyFile: filepath.Base(filename),
+		Created: info.ModTime(),
+		Base64:  base64.StdEncoding.EncodeToString(content),
+	}
+
+	if !sss.IsWrappedPart(content) {
+		part, err := sss.NewPartFromContent(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading partial key '%s'", filename)
+		}
+
+		s.Part = &part
+	}
+
+	s.Mnemonic, err = mnemonic.Encode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	png, err := qr.EncodePNG([]byte(s.Base64))
+	if err != nil {
+		return nil, err
+	}
+
+	// the data URI is generated from the PNG image, it is not user input
+	s.QRCode = template.URL( //nolint:gosec
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+
+	if encryptedFile != "" {
+		s.EncryptedFile = filepath.Base(encryptedFile)
+		s.SecretName = secretName(encryptedFile)
+	}
+
+	return s, nil
+}
+
+// secretName returns the name of the secret stored in the header of the encrypted file,
+// or the name of the file without the .enc extension for the files without header.
+func secretName(encryptedFile string) string {
+	name := strings.TrimSuffix(filepath.Base(encryptedFile), ".enc")
+
+	f, err := os.Open(encryptedFile)
+	if err != nil {
+		return name
+	}
+	defer f.Close()
+
+	header, err := sss.ReadHeader(f)
+	if err != nil || header.Filename == "" {
+		return name
+	}
+
+	return header.Filename
+}
+
+// SetID returns the share set ID of the partial key, if any.
+func (s *Sheet) SetID() string {
+	if s.Part == nil || s.Part.SetID.IsZero() {
+		return ""
+	}
+
+	return s.Part.SetID.String()
+}
+
+// Write renders the sheet as an HTML page.
+func (s *Sheet) Write(w io.Writer) error {
+	return errors.Wrap(tmpl.Execute(w, s), "failed rendering sheet")
+}
@@ -0,0 +1,34 @@
+package sheet_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/sheet"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewFromKeyFile_Protected(t *testing.T) {
+	parts, err := sss.Split([]byte("test secret"), 3, 2)
+	require.NoError(t, err)
+
+	wrapped, err := sss.WrapPart(parts[0], []byte("alice"))
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "001")
+	require.NoError(t, file.WriteKey(nil, wrapped, keyFile))
+
+	s, err := sheet.NewFromKeyFile(keyFile+".key", "")
+	require.NoError(t, err)
+	assert.Nil(t, s.Part)
+	assert.Empty(t, s.SetID())
+
+	out := &bytes.Buffer{}
+	require.NoError(t, s.Write(out))
+	assert.Contains(t, out.String(), "encrypted with the passphrase of the holder")
+	assert.Contains(t, out.String(), "stego decrypt -f SECRET.enc --key 001.key")
+}
@@ -0,0 +1,34 @@
+package verify
+
+import (
+	"github.com/enrichman/stegosecrets/pkg/file"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/pkg/errors"
+)
+
+// ReadCommitmentsFile reads the commitments saved along with the encrypted file, to verify the parts.
+func ReadCommitmentsFile(filename string) (*sss.Commitments, error) {
+	content, err := file.ReadKey(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading commitments file")
+	}
+
+	commitments, err := sss.UnmarshalCommitments(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed parsing commitments file '%s'", filename)
+	}
+
+	return commitments, nil
+}
+
+// Shares verifies every part against the commitments, without combining them:
+// a holder can check its own part, without knowing the others.
+func Shares(commitments *sss.Commitments, parts map[string]sss.Part) Report {
+	report := Report{}
+
+	for _, filename := range sortedKeys(parts) {
+		report.add(filename, CheckCommitments, commitments.Verify(parts[filename]))
+	}
+
+	return report
+}
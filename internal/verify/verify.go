@@ -0,0 +1,319 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/enrichman/stegosecrets/internal/decrypt"
+	"github.com/enrichman/stegosecrets/pkg/armor"
+	"github.com/enrichman/stegosecrets/pkg/file"
+	sss "github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/pkg/errors"
+)
+
+const (
+	CheckChecksum    = "checksum"
+	CheckShare       = "share"
+	CheckHeader      = "header"
+	CheckConsistency = "consistency"
+	CheckCommitments = "commitments"
+)
+
+// Result is the outcome of a single check on a file.
+type Result struct {
+	File  string
+	Check string
+	Err   error
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("❌ %s [%s]: %s", r.File, r.Check, r.Err)
+	}
+
+	return fmt.Sprintf("✅ %s [%s]", r.File, r.Check)
+}
+
+type Report []Result
+
+// Failed returns the number of failed checks.
+func (r Report) Failed() int {
+	failed := 0
+
+	for _, result := range r {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// PINFunc returns the PIN of the holder of the image, for the images protected by a PIN (see lsb-scatter).
+type PINFunc func(filename string) ([]byte, error)
+
+func (r *Report) add(filename, check string, err error) {
+	*r = append(*r, Result{File: filename, Check: check, Err: err})
+}
+
+// Dir verifies an output directory created by the encryption: the checksum files, the shares hidden in the
+// images and saved in the partial key files, and their consistency (also with the commitments, if found).
+// If pin is not nil the shares are read from the images with the PIN of their holders.
+func Dir(dir string, pin PINFunc) (Report, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading directory '%s'", dir)
+	}
+
+	report := Report{}
+	keys := map[string]sss.Part{}
+	images := map[string]sss.Part{}
+	codes := map[string]sss.Part{}
+
+	var (
+		header      *sss.Header
+		commitments *sss.Commitments
+	)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		filename := filepath.Join(dir, entry.Name())
+
+		switch ext := filepath.Ext(filename); {
+		case ext == ".checksum":
+			target := strings.TrimSuffix(filename, ".checksum")
+
+			// the checksum of the original file can be verified only after the decryption
+			if !exists(target) && exists(target+".enc") {
+				continue
+			}
+
+			report.add(filename, CheckChecksum, file.Check(target, filename))
+		case strings.HasSuffix(filename, file.QRExtension):
+			part, err := decrypt.ReadPartialKeyQRFile(filename)
+			report.add(filename, CheckShare, ignoreWrapped(err))
+
+			if err == nil {
+				codes[filename] = part
+			}
+		case ext == ".png" || ext == ".jpg" || ext == ".jpeg":
+			var key []byte
+			if pin != nil {
+				if key, err = pin(filename); err != nil {
+					return nil, errors.Wrapf(err, "failed reading PIN of '%s'", filename)
+				}
+			}
+
+			part, err := readPartialKeyImage(filename, key)
+			report.add(filename, CheckShare, ignoreWrapped(err))
+
+			if err == nil {
+				images[filename] = part
+			}
+		case ext == ".key" && !strings.HasSuffix(filename, ".enc.key"):
+			part, err := decrypt.ReadPartialKeyFile(filename)
+			report.add(filename, CheckShare, ignoreWrapped(err))
+
+			if err == nil {
+				keys[filename] = part
+			}
+		case ext == ".enc":
+			h, err := readHeader(filename)
+			if !errors.Is(err, sss.ErrMissingHeader) {
+				report.add(filename, CheckHeader, err)
+			}
+
+			header = h
+		case ext == ".commitments":
+			commitments, err = ReadCommitmentsFile(filename)
+			if err != nil {
+				report.add(filename, CheckCommitments, err)
+			}
+		}
+	}
+
+	report = append(report, checkConsistency(keys, images, header)...)
+	report = append(report, checkQRCodes(codes, keys)...)
+
+	if commitments != nil {
+		report = append(report, Shares(commitments, keys)...)
+		report = append(report, Shares(commitments, images)...)
+		report = append(report, Shares(commitments, codes)...)
+	}
+
+	return report, nil
+}
+
+// readPartialKeyImage reads the share hidden in the image, with the PIN of its holder if not nil.
+func readPartialKeyImage(filename string, pin []byte) (sss.Part, error) {
+	if pin == nil {
+		return decrypt.ReadPartialKeyImageFile(filename)
+	}
+
+	return decrypt.ReadPartialKeyImageFilePIN(filename, pin)
+}
+
+// ignoreWrapped ignores the error of the shares protected by the passphrase of their holders:
+// they cannot be checked without it.
+func ignoreWrapped(err error) error {
+	if errors.Is(err, sss.ErrWrappedPart) {
+		return nil
+	}
+
+	return err
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+
+	return err == nil
+}
+
+func readHeader(filename string) (*sss.Header, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer f.Close()
+
+	r, err := armor.Unwrap(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading armored file '%s'", filename)
+	}
+
+	return sss.ReadHeader(r)
+}
+
+// checkConsistency checks that all the shares belong to the same split of the master key.
+func checkConsistency(keys, images map[string]sss.Part, header *sss.Header) Report {
+	report := Report{}
+
+	all := map[string]sss.Part{}
+	for filename, part := range keys {
+		all[filename] = part
+	}
+
+	for filename, part := range images {
+		all[filename] = part
+	}
+
+	if len(all) == 0 {
+		return report
+	}
+
+	filenames := sortedKeys(all)
+
+	// the reference share is the first one, or the header if available
+	reference := all[filenames[0]]
+	referenceName := filenames[0]
+
+	if header != nil && header.Parts > 1 {
+		reference.Parts, reference.Threshold = header.Parts, header.Threshold
+		referenceName = "header"
+
+		if !header.ShareSetID.IsZero() {
+			reference.SetID = header.ShareSetID
+		}
+	}
+
+	for _, filename := range filenames {
+		part := all[filename]
+
+		var err error
+
+		switch {
+		case part.Version != all[filenames[0]].Version:
+			err = errors.Errorf("version %c differs from %c of '%s'", part.Version, all[filenames[0]].Version, filenames[0])
+		case part.Parts != reference.Parts || part.Threshold != reference.Threshold:
+			err = errors.Errorf("parts/threshold %d/%d differ from %d/%d of '%s'",
+				part.Parts, part.Threshold, reference.Parts, reference.Threshold, referenceName)
+		case part.SetID != reference.SetID:
+			err = errors.Errorf("share set '%s' differs from '%s' of '%s'", part.SetID, reference.SetID, referenceName)
+		default:
+			err = checkCounterpart(filename, part, keys, images)
+		}
+
+		report.add(filename, CheckConsistency, err)
+	}
+
+	report = append(report, checkUniqueTags(keys)...)
+	report = append(report, checkUniqueTags(images)...)
+
+	return report
+}
+
+// checkCounterpart checks that the partial key file and the image with the same name hold the same share.
+func checkCounterpart(filename string, part sss.Part, keys, images map[string]sss.Part) error {
+	if _, isKey := keys[filename]; !isKey {
+		return nil
+	}
+
+	base := strings.TrimSuffix(filename, ".key")
+
+	for imageFilename, imagePart := range images {
+		if strings.TrimSuffix(imageFilename, filepath.Ext(imageFilename)) != base {
+			continue
+		}
+
+		if !bytes.Equal(part.Bytes(), imagePart.Bytes()) {
+			return errors.Errorf("share differs from the one hidden in '%s'", imageFilename)
+		}
+	}
+
+	return nil
+}
+
+// checkQRCodes checks that the QR codes hold the same share of the partial key files with the same name.
+func checkQRCodes(codes, keys map[string]sss.Part) Report {
+	report := Report{}
+
+	for _, filename := range sortedKeys(codes) {
+		keyFilename := strings.TrimSuffix(filename, file.QRExtension) + ".key"
+
+		var err error
+		if part, found := keys[keyFilename]; found && !bytes.Equal(part.Bytes(), codes[filename].Bytes()) {
+			err = errors.Errorf("share differs from the one of '%s'", keyFilename)
+		}
+
+		report.add(filename, CheckConsistency, err)
+	}
+
+	return report
+}
+
+func checkUniqueTags(parts map[string]sss.Part) Report {
+	report := Report{}
+	tags := map[byte]string{}
+
+	for _, filename := range sortedKeys(parts) {
+		tag := parts[filename].Tag
+
+		if other, found := tags[tag]; found {
+			report.add(filename, CheckConsistency, errors.Errorf("duplicated tag %d, same share of '%s'", tag, other))
+
+			continue
+		}
+
+		tags[tag] = filename
+	}
+
+	return report
+}
+
+func sortedKeys(parts map[string]sss.Part) []string {
+	keys := make([]string, 0, len(parts))
+	for k := range parts {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,123 @@
+package verify_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/internal/encrypt"
+	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/internal/verify"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptToDir(t *testing.T, opts ...encrypt.OptFunc) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	encrypter, err := encrypt.NewEncrypter(append([]encrypt.OptFunc{
+		encrypt.WithPartsAndThreshold(3, 2),
+		encrypt.WithOutputDir(tmpDir),
+		encrypt.WithImagesDir("../../test/assets/p5t3"),
+		encrypt.WithLogger(log.NewSimpleLogger(io.Discard, log.None)),
+	}, opts...)...)
+	require.NoError(t, err)
+
+	err = encrypter.Encrypt(strings.NewReader("hello world!"), "secret")
+	require.NoError(t, err)
+
+	return tmpDir
+}
+
+func failedFiles(report verify.Report) []string {
+	failed := []string{}
+
+	for _, result := range report {
+		if result.Err != nil {
+			failed = append(failed, filepath.Base(result.File)+" "+result.Check)
+		}
+	}
+
+	return failed
+}
+
+func TestDir(t *testing.T) {
+	report, err := verify.Dir(encryptToDir(t), nil)
+	require.NoError(t, err)
+
+	assert.Zero(t, report.Failed(), report)
+	assert.NotEmpty(t, report)
+}
+
+func TestDir_PIN(t *testing.T) {
+	dir := encryptToDir(t,
+		encrypt.WithSteganographer("lsb-scatter"),
+		encrypt.WithImagePINs([][]byte{[]byte("1111"), []byte("2222"), []byte("3333")}),
+	)
+
+	pins := map[string]string{"001.png": "1111", "002.png": "2222", "003.png": "3333"}
+	pin := func(filename string) ([]byte, error) {
+		return []byte(pins[filepath.Base(filename)]), nil
+	}
+
+	report, err := verify.Dir(dir, pin)
+	require.NoError(t, err)
+	assert.Zero(t, report.Failed(), report)
+
+	// without the PINs the shares cannot be read from the images
+	report, err = verify.Dir(dir, nil)
+	require.NoError(t, err)
+	assert.NotZero(t, report.Failed())
+
+	pins["002.png"] = "0000"
+
+	report, err = verify.Dir(dir, pin)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002.png share"}, failedFiles(report))
+
+	_, err = verify.Dir(dir, func(string) ([]byte, error) { return nil, errors.New("empty password") })
+	require.Error(t, err)
+}
+
+func TestDir_Failures(t *testing.T) {
+	t.Run("wrong checksum", func(t *testing.T) {
+		dir := encryptToDir(t)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.enc"), []byte("tampered"), 0o600))
+
+		report, err := verify.Dir(dir, nil)
+		require.NoError(t, err)
+
+		assert.Contains(t, failedFiles(report), "secret.enc.checksum checksum")
+	})
+
+	t.Run("duplicated share", func(t *testing.T) {
+		dir := encryptToDir(t)
+
+		key, err := os.ReadFile(filepath.Join(dir, "001.key"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "004.key"), key, 0o600))
+
+		report, err := verify.Dir(dir, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"004.key consistency"}, failedFiles(report))
+	})
+
+	t.Run("share from another split", func(t *testing.T) {
+		dir := encryptToDir(t)
+
+		key, err := os.ReadFile("../../test/assets/p5t3/001.key")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "002.key"), key, 0o600))
+
+		report, err := verify.Dir(dir, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"002.key consistency"}, failedFiles(report))
+	})
+}
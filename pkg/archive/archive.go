@@ -0,0 +1,329 @@
+// Package archive stores a directory tree as a tar archive, optionally compressed with gzip,
+// to encrypt whole directories. The modes and the modification times of the files are preserved.
+//
+// The extraction is protected against path traversal: the entries, and the targets of the symbolic links,
+// cannot point outside the target directory.
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gzipMagic are the first bytes of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ErrUnsafePath is returned when an entry of the archive points outside the target directory.
+var ErrUnsafePath = errors.New("unsafe path in archive")
+
+// Write writes the directory as a tar archive, compressed with gzip if compress is set.
+// The entries are stored under the name of the directory, as with 'tar -c DIR'.
+// The excluded paths (i.e. the output directory, if inside) are skipped.
+func Write(w io.Writer, dir string, compress bool, exclude ...string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed getting absolute path of '%s'", dir)
+	}
+
+	excluded := map[string]bool{}
+	for _, path := range exclude {
+		if abs, err := filepath.Abs(path); err == nil {
+			excluded[abs] = true
+		}
+	}
+
+	out := w
+
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+
+	tw := tar.NewWriter(out)
+	base := filepath.Base(root)
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if excluded[path] {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		return writeEntry(tw, path, filepath.ToSlash(filepath.Join(base, rel)))
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed archiving directory '%s'", dir)
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed writing archive")
+	}
+
+	if gz != nil {
+		return errors.Wrap(gz.Close(), "failed compressing archive")
+	}
+
+	return nil
+}
+
+func writeEntry(tw *tar.Writer, path, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		name += "/"
+	case mode&fs.ModeSymlink != 0:
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	case !mode.IsRegular():
+		return errors.Errorf("unsupported file '%s' (%s)", path, mode.Type())
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+
+	return err
+}
+
+// Extract extracts the tar archive into the directory, creating it if needed.
+// The gzip compression is detected automatically.
+// Only directories, regular files and symbolic links pointing inside the directory are supported.
+func Extract(r io.Reader, dir string) error {
+	reader := bufio.NewReader(r)
+
+	var in io.Reader = reader
+
+	// error ignored: an empty content is not compressed
+	if magic, _ := reader.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return errors.Wrap(err, "failed decompressing archive")
+		}
+		defer gz.Close()
+
+		in = gz
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "failed creating directory '%s'", dir)
+	}
+
+	root, err := resolvePath(dir)
+	if err != nil {
+		return err
+	}
+
+	// the directories are completed at the end, since extracting their files changes their times,
+	// and a read-only directory would not be writable
+	dirs := []*tar.Header{}
+
+	tr := tar.NewReader(in)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed reading archive")
+		}
+
+		path, err := extractEntry(tr, header, root)
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			header.Name = path
+			dirs = append(dirs, header)
+		}
+	}
+
+	// the nested directories first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].Name, dirs[i].FileInfo().Mode().Perm()); err != nil {
+			return errors.Wrapf(err, "failed setting mode of '%s'", dirs[i].Name)
+		}
+
+		if err := os.Chtimes(dirs[i].Name, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return errors.Wrapf(err, "failed setting times of '%s'", dirs[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// extractEntry extracts the entry into the root directory, returning its path.
+func extractEntry(tr *tar.Reader, header *tar.Header, root string) (string, error) {
+	name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+	if !filepath.IsLocal(name) {
+		return "", errors.Wrapf(ErrUnsafePath, "'%s'", header.Name)
+	}
+
+	path := filepath.Join(root, name)
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := checkInside(root, path); err != nil {
+			return "", err
+		}
+
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return "", errors.Wrapf(err, "failed creating directory '%s'", path)
+		}
+
+		return path, nil
+	case tar.TypeSymlink:
+		// the target is relative to the directory of the link
+		target := filepath.FromSlash(header.Linkname)
+		if filepath.IsAbs(target) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), target)) {
+			return "", errors.Wrapf(ErrUnsafePath, "link '%s' to '%s'", header.Name, header.Linkname)
+		}
+
+		if err := prepareFile(root, path); err != nil {
+			return "", err
+		}
+
+		return path, errors.Wrapf(os.Symlink(target, path), "failed creating link '%s'", path)
+	case tar.TypeReg:
+		if err := prepareFile(root, path); err != nil {
+			return "", err
+		}
+
+		return path, extractFile(tr, header, path)
+	default:
+		return "", errors.Errorf("unsupported entry '%s' of type '%c' in archive", header.Name, header.Typeflag)
+	}
+}
+
+// prepareFile creates the directory of the file, removing the existing file or link:
+// the files are never written through an existing link.
+func prepareFile(root, path string) error {
+	dir := filepath.Dir(path)
+
+	if err := checkInside(root, dir); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.Wrapf(err, "failed creating directory of '%s'", path)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+
+	if info.IsDir() {
+		return errors.Errorf("failed extracting '%s': it is a directory", path)
+	}
+
+	return errors.Wrapf(os.Remove(path), "failed replacing '%s'", path)
+}
+
+func extractFile(tr *tar.Reader, header *tar.Header, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, header.FileInfo().Mode().Perm())
+	if err != nil {
+		return errors.Wrapf(err, "failed creating file '%s'", path)
+	}
+
+	_, err = io.Copy(f, tr)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed extracting file '%s'", path)
+	}
+
+	// the mode set by OpenFile is restricted by the umask
+	if err := os.Chmod(path, header.FileInfo().Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "failed setting mode of '%s'", path)
+	}
+
+	return errors.Wrapf(os.Chtimes(path, header.ModTime, header.ModTime), "failed setting times of '%s'", path)
+}
+
+// checkInside verifies that the path, following the links of its existing part, is inside the root directory.
+func checkInside(root, path string) error {
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil || filepath.Dir(existing) == existing {
+			break
+		}
+
+		existing = filepath.Dir(existing)
+	}
+
+	resolved, err := resolvePath(existing)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return errors.Wrapf(ErrUnsafePath, "'%s' is outside '%s'", path, root)
+	}
+
+	return nil
+}
+
+// resolvePath returns the absolute path, following the links.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed getting absolute path of '%s'", path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+
+	return resolved, errors.Wrapf(err, "failed resolving path '%s'", path)
+}
@@ -0,0 +1,150 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/enrichman/stegosecrets/pkg/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteExtract(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	src := filepath.Join(t.TempDir(), "mydir")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub", "empty"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Symlink(filepath.Join("sub", "run.sh"), filepath.Join(src, "link")))
+	require.NoError(t, os.Chtimes(filepath.Join(src, "a.txt"), mtime, mtime))
+	require.NoError(t, os.Chtimes(filepath.Join(src, "sub"), mtime, mtime))
+
+	// the output directory is excluded
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "out"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "out", "secret.enc"), []byte("encrypted"), 0o600))
+
+	for _, compress := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, archive.Write(buf, src, compress, filepath.Join(src, "out")))
+		assert.Equal(t, compress, bytes.HasPrefix(buf.Bytes(), []byte{0x1f, 0x8b}))
+
+		dst := t.TempDir()
+		require.NoError(t, archive.Extract(buf, dst))
+
+		content, err := os.ReadFile(filepath.Join(dst, "mydir", "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), content)
+
+		info, err := os.Stat(filepath.Join(dst, "mydir", "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		assert.True(t, mtime.Equal(info.ModTime()))
+
+		info, err = os.Stat(filepath.Join(dst, "mydir", "sub", "run.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+		info, err = os.Stat(filepath.Join(dst, "mydir", "sub"))
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(info.ModTime()))
+
+		assert.DirExists(t, filepath.Join(dst, "mydir", "sub", "empty"))
+		assert.NoDirExists(t, filepath.Join(dst, "mydir", "out"))
+
+		target, err := os.Readlink(filepath.Join(dst, "mydir", "link"))
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("sub", "run.sh"), target)
+	}
+}
+
+func Test_ExtractUnsafe(t *testing.T) {
+	tt := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{
+			name:    "parent directory",
+			headers: []*tar.Header{{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0o600}},
+		},
+		{
+			name:    "nested parent directory",
+			headers: []*tar.Header{{Name: "dir/../../evil.txt", Typeflag: tar.TypeReg, Mode: 0o600}},
+		},
+		{
+			name:    "absolute path",
+			headers: []*tar.Header{{Name: "/tmp/evil.txt", Typeflag: tar.TypeReg, Mode: 0o600}},
+		},
+		{
+			name:    "absolute link",
+			headers: []*tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		},
+		{
+			name:    "link outside",
+			headers: []*tar.Header{{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "../.."}},
+		},
+		{
+			name:    "unsupported entry",
+			headers: []*tar.Header{{Name: "hard", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			dst := filepath.Join(root, "dst")
+
+			err := archive.Extract(bytes.NewReader(tarOf(t, tc.headers)), dst)
+			require.Error(t, err)
+			assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+		})
+	}
+}
+
+func Test_ExtractThroughExistingLink(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(root, "outside")
+	dst := filepath.Join(root, "dst")
+
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.MkdirAll(dst, 0o755))
+
+	// a link already in the target directory
+	require.NoError(t, os.Symlink(outside, filepath.Join(dst, "dir")))
+
+	content := tarOf(t, []*tar.Header{{Name: "dir/evil.txt", Typeflag: tar.TypeReg, Mode: 0o600}})
+
+	err := archive.Extract(bytes.NewReader(content), dst)
+	require.ErrorIs(t, err, archive.ErrUnsafePath)
+	assert.NoFileExists(t, filepath.Join(outside, "evil.txt"))
+
+	// an existing link is replaced, not followed
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "file.txt"), []byte("untouched"), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "file.txt"), filepath.Join(dst, "file.txt")))
+
+	content = tarOf(t, []*tar.Header{{Name: "file.txt", Typeflag: tar.TypeReg, Mode: 0o600}})
+	require.NoError(t, archive.Extract(bytes.NewReader(content), dst))
+
+	untouched, err := os.ReadFile(filepath.Join(outside, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("untouched"), untouched)
+}
+
+func tarOf(t *testing.T, headers []*tar.Header) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, header := range headers {
+		require.NoError(t, tw.WriteHeader(header))
+	}
+
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
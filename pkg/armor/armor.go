@@ -0,0 +1,365 @@
+// Package armor implements an ASCII armored encoding, similar to the one of OpenPGP (RFC 4880),
+// to paste the encrypted files and the partial keys in emails, tickets or configuration files:
+//
+//	-----BEGIN STEGO SECRET-----
+//	Filename: secret.txt
+//
+//	U1RHUwEB...
+//	=njUN
+//	-----END STEGO SECRET-----
+//
+// The content is base64 encoded in lines of 64 characters, followed by its CRC-24.
+// The headers are informational: they are not authenticated.
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The types of the armored blocks.
+const (
+	TypeSecret     = "STEGO SECRET"
+	TypePartialKey = "STEGO PARTIAL KEY"
+)
+
+const (
+	beginPrefix = "-----BEGIN "
+	endPrefix   = "-----END "
+	lineSuffix  = "-----"
+	lineLength  = 64
+
+	crc24Init = 0xb704ce
+	crc24Poly = 0x1864cfb
+)
+
+var (
+	// ErrInvalid is returned when the content is not a valid armored block.
+	ErrInvalid = errors.New("invalid armored content")
+	// ErrChecksum is returned when the content does not match its CRC-24.
+	ErrChecksum = errors.New("armored content checksum mismatch")
+)
+
+// Block is a decoded armored block.
+type Block struct {
+	Type    string
+	Headers map[string]string
+	// Body is the decoded content. The checksum is verified when the end of the block is reached.
+	Body io.Reader
+}
+
+// IsArmored reports whether the content starts with an armored block of stego (leading spaces are ignored).
+func IsArmored(prefix []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(prefix, " \t\r\n"), []byte(beginPrefix+"STEGO"))
+}
+
+// Encode returns a writer encoding the content written into an armored block. The block is completed
+// when the writer is closed.
+func Encode(w io.Writer, blockType string, headers map[string]string) (io.WriteCloser, error) {
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "%s%s%s\n", beginPrefix, blockType, lineSuffix)
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s: %s\n", key, headers[key])
+	}
+
+	out.WriteString("\n")
+
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "failed writing armor header")
+	}
+
+	lines := &lineWriter{w: w}
+
+	return &encoder{
+		w:         w,
+		lines:     lines,
+		b64:       base64.NewEncoder(base64.StdEncoding, lines),
+		blockType: blockType,
+		crc:       crc24Init,
+	}, nil
+}
+
+// Marshal returns the armored block of the content.
+func Marshal(blockType string, headers map[string]string, content []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+
+	w, err := Encode(out, blockType, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+type encoder struct {
+	w         io.Writer
+	lines     *lineWriter
+	b64       io.WriteCloser
+	blockType string
+	crc       uint32
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	e.crc = crc24(e.crc, p)
+
+	return e.b64.Write(p)
+}
+
+func (e *encoder) Close() error {
+	if err := e.b64.Close(); err != nil {
+		return err
+	}
+
+	if e.lines.n > 0 {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	crc := []byte{byte(e.crc >> 16), byte(e.crc >> 8), byte(e.crc)}
+
+	_, err := fmt.Fprintf(e.w, "=%s\n%s%s%s\n", base64.StdEncoding.EncodeToString(crc), endPrefix, e.blockType, lineSuffix)
+
+	return errors.Wrap(err, "failed writing armor footer")
+}
+
+// lineWriter splits the base64 content in lines.
+type lineWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		chunk := p[:min(len(p), lineLength-l.n)]
+
+		n, err := l.w.Write(chunk)
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+
+		l.n += n
+		p = p[n:]
+
+		if l.n == lineLength {
+			if _, err := io.WriteString(l.w, "\n"); err != nil {
+				return written, err
+			}
+
+			l.n = 0
+		}
+	}
+
+	return written, nil
+}
+
+// Decode reads the armored block. The lines can be indented, and the content can be wrapped
+// at a different length.
+func Decode(r io.Reader) (*Block, error) {
+	reader := bufio.NewReader(r)
+
+	line, err := readLine(reader)
+	for err == nil && line == "" {
+		line, err = readLine(reader)
+	}
+
+	if err != nil || !strings.HasPrefix(line, beginPrefix) || !strings.HasSuffix(line, lineSuffix) {
+		return nil, ErrInvalid
+	}
+
+	block := &Block{
+		Type:    strings.TrimSuffix(strings.TrimPrefix(line, beginPrefix), lineSuffix),
+		Headers: map[string]string{},
+	}
+
+	dec := &decoder{reader: reader, blockType: block.Type, crc: crc24Init}
+
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+
+		key, value, isHeader := strings.Cut(line, ": ")
+		if line == "" || !isHeader {
+			// the blank line after the headers is optional
+			dec.pending = line
+
+			break
+		}
+
+		block.Headers[key] = value
+	}
+
+	block.Body = dec
+
+	return block, nil
+}
+
+// Unmarshal decodes the armored block, reading all its content.
+func Unmarshal(content []byte) (*Block, []byte, error) {
+	block, err := Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := io.ReadAll(block.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, body, nil
+}
+
+// Unwrap returns a reader of the decoded content if the content is armored,
+// otherwise a reader of the content as it is.
+func Unwrap(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+
+	// error ignored: a short content will not be armored
+	prefix, _ := reader.Peek(len(beginPrefix) + 16)
+	if !IsArmored(prefix) {
+		return reader, nil
+	}
+
+	block, err := Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Body, nil
+}
+
+type decoder struct {
+	reader    *bufio.Reader
+	blockType string
+	// pending is a line already read, and base64 the characters not decoded yet
+	pending string
+	base64  string
+	decoded []byte
+	crc     uint32
+	err     error
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.decoded) == 0 && d.err == nil {
+		d.err = d.next()
+	}
+
+	if len(d.decoded) == 0 {
+		return 0, d.err
+	}
+
+	n := copy(p, d.decoded)
+	d.decoded = d.decoded[n:]
+
+	return n, nil
+}
+
+// next decodes the next line, returning io.EOF at the end of the block.
+func (d *decoder) next() error {
+	line := d.pending
+	d.pending = ""
+
+	if line == "" {
+		var err error
+		if line, err = readLine(d.reader); err != nil {
+			return errors.Wrap(ErrInvalid, "missing end of armored block")
+		}
+	}
+
+	// the checksum line is "=" followed by the 4 characters of the CRC, not to be confused with the padding
+	if !strings.HasPrefix(line, "=") || len(line) != 5 {
+		d.base64 += line
+
+		// the characters are decoded in groups of four
+		size := len(d.base64) / 4 * 4
+		if strings.Contains(d.base64, "=") {
+			size = len(d.base64)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(d.base64[:size])
+		if err != nil {
+			return errors.Wrap(ErrInvalid, err.Error())
+		}
+
+		d.base64 = d.base64[size:]
+		d.decoded = decoded
+		d.crc = crc24(d.crc, decoded)
+
+		return nil
+	}
+
+	if d.base64 != "" {
+		return errors.Wrap(ErrInvalid, "truncated base64 content")
+	}
+
+	crc, err := base64.StdEncoding.DecodeString(line[1:])
+	if err != nil || len(crc) != 3 {
+		return errors.Wrap(ErrInvalid, "invalid checksum")
+	}
+
+	if uint32(crc[0])<<16|uint32(crc[1])<<8|uint32(crc[2]) != d.crc {
+		return ErrChecksum
+	}
+
+	end, err := readLine(d.reader)
+	if err != nil || end != endPrefix+d.blockType+lineSuffix {
+		return errors.Wrap(ErrInvalid, "missing end of armored block")
+	}
+
+	return io.EOF
+}
+
+// readLine returns the next line without the leading and trailing spaces.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// crc24 updates the CRC-24 of OpenPGP with the data.
+func crc24(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+
+	return crc & 0xffffff
+}
@@ -0,0 +1,98 @@
+package armor_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MarshalUnmarshal(t *testing.T) {
+	for _, size := range []int{0, 1, 47, 48, 49, 1000} {
+		content := make([]byte, size)
+		_, err := rand.Read(content)
+		require.NoError(t, err)
+
+		headers := map[string]string{"Filename": "secret.txt", "Parts": "5"}
+
+		armored, err := armor.Marshal(armor.TypeSecret, headers, content)
+		require.NoError(t, err)
+		assert.True(t, armor.IsArmored(armored))
+		assert.True(t, strings.HasPrefix(string(armored), "-----BEGIN STEGO SECRET-----\nFilename: secret.txt\nParts: 5\n\n"))
+		assert.True(t, strings.HasSuffix(string(armored), "-----END STEGO SECRET-----\n"))
+
+		for _, line := range strings.Split(string(armored), "\n") {
+			assert.LessOrEqual(t, len(line), 64)
+		}
+
+		block, decoded, err := armor.Unmarshal(armored)
+		require.NoError(t, err)
+		assert.Equal(t, armor.TypeSecret, block.Type)
+		assert.Equal(t, headers, block.Headers)
+		assert.Equal(t, content, decoded)
+	}
+}
+
+func Test_DecodePasted(t *testing.T) {
+	content := bytes.Repeat([]byte("pasted content "), 20)
+
+	armored, err := armor.Marshal(armor.TypePartialKey, nil, content)
+	require.NoError(t, err)
+
+	// indented in a YAML file, with CRLF line endings and the lines wrapped again
+	lines := strings.Split(string(armored), "\n")
+	pasted := "\r\n    " + lines[0] + "\r\n\r\n"
+
+	b64 := strings.Join(lines[2:len(lines)-3], "")
+	for len(b64) > 0 {
+		n := min(len(b64), 50)
+		pasted += "    " + b64[:n] + "\r\n"
+		b64 = b64[n:]
+	}
+
+	pasted += "    " + lines[len(lines)-3] + "\r\n    " + lines[len(lines)-2] + "\r\n"
+
+	_, decoded, err := armor.Unmarshal([]byte(pasted))
+	require.NoError(t, err)
+	assert.Equal(t, content, decoded)
+
+	reader, err := armor.Unwrap(strings.NewReader(pasted))
+	require.NoError(t, err)
+
+	decoded, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, decoded)
+
+	// the content which is not armored is returned as it is
+	reader, err = armor.Unwrap(bytes.NewReader(content))
+	require.NoError(t, err)
+
+	decoded, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, decoded)
+}
+
+func Test_DecodeCorrupted(t *testing.T) {
+	armored, err := armor.Marshal(armor.TypePartialKey, nil, []byte("a partial key"))
+	require.NoError(t, err)
+
+	lines := strings.Split(string(armored), "\n")
+
+	// a character changed in the content
+	corrupted := strings.Split(string(armored), "\n")
+	corrupted[2] = "b" + corrupted[2][1:]
+	_, _, err = armor.Unmarshal([]byte(strings.Join(corrupted, "\n")))
+	require.ErrorIs(t, err, armor.ErrChecksum)
+
+	// the end of the block is missing
+	_, _, err = armor.Unmarshal([]byte(strings.Join(lines[:len(lines)-2], "\n")))
+	require.ErrorIs(t, err, armor.ErrInvalid)
+
+	_, _, err = armor.Unmarshal([]byte("not armored"))
+	require.ErrorIs(t, err, armor.ErrInvalid)
+}
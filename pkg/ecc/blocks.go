@@ -0,0 +1,105 @@
+package ecc
+
+import (
+	"github.com/pkg/errors"
+)
+
+// BlocksSize returns the size of the codeword of dataLen bytes encoded by EncodeBlocks.
+func BlocksSize(dataLen, parity int) int {
+	return dataLen + blocksCount(dataLen, parity)*parity
+}
+
+func blocksCount(dataLen, parity int) int {
+	perBlock := MaxBlockSize - parity
+	if dataLen == 0 || perBlock <= 0 {
+		return 1
+	}
+
+	return (dataLen + perBlock - 1) / perBlock
+}
+
+// blockSizes returns the data bytes of every block: the data is split evenly between the blocks.
+func blockSizes(dataLen, parity int) []int {
+	count := blocksCount(dataLen, parity)
+
+	sizes := make([]int, count)
+	for i := range sizes {
+		sizes[i] = dataLen / count
+		if i < dataLen%count {
+			sizes[i]++
+		}
+	}
+
+	return sizes
+}
+
+// EncodeBlocks encodes data of any size splitting it in blocks with the given parity bytes each.
+// The bytes of the blocks are interleaved, so a burst of corrupted bytes is spread across all the blocks.
+func EncodeBlocks(data []byte, parity int) ([]byte, error) {
+	blocks := [][]byte{}
+
+	offset := 0
+	for _, size := range blockSizes(len(data), parity) {
+		block, err := Encode(data[offset:offset+size], parity)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+		offset += size
+	}
+
+	return interleave(blocks, BlocksSize(len(data), parity)), nil
+}
+
+// DecodeBlocks decodes the data of dataLen bytes encoded by EncodeBlocks, returning the number of corrected bytes.
+func DecodeBlocks(codeword []byte, dataLen, parity int) ([]byte, int, error) {
+	if len(codeword) != BlocksSize(dataLen, parity) {
+		return nil, 0, errors.Errorf("invalid codeword size: %d bytes, expected %d", len(codeword), BlocksSize(dataLen, parity))
+	}
+
+	sizes := blockSizes(dataLen, parity)
+
+	blocks := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		blocks[i] = make([]byte, 0, size+parity)
+	}
+
+	for i := 0; len(codeword) > 0; i++ {
+		for b := range blocks {
+			if i < sizes[b]+parity {
+				blocks[b] = append(blocks[b], codeword[0])
+				codeword = codeword[1:]
+			}
+		}
+	}
+
+	data := make([]byte, 0, dataLen)
+	corrected := 0
+
+	for i, block := range blocks {
+		decoded, n, err := Decode(block, parity)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "failed decoding block %d", i)
+		}
+
+		data = append(data, decoded...)
+		corrected += n
+	}
+
+	return data, corrected, nil
+}
+
+func interleave(blocks [][]byte, size int) []byte {
+	out := make([]byte, 0, size)
+
+	for i := 0; len(out) < size; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,331 @@
+// Package ecc implements a Reed-Solomon error correcting code over GF(2^8).
+package ecc
+
+import (
+	"github.com/pkg/errors"
+)
+
+// MaxBlockSize is the maximum size of a codeword (data and parity bytes).
+const MaxBlockSize = 255
+
+// ErrTooManyErrors is returned when the codeword has more errors than the parity bytes can correct.
+var ErrTooManyErrors = errors.New("too many errors to correct")
+
+// exp and log tables of GF(2^8) with the primitive polynomial x^8+x^4+x^3+x^2+1 (0x11d)
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var (
+		exp [512]byte
+		log [256]byte
+	)
+
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+
+	return exp, log
+}()
+
+func gfMul(x, y byte) byte {
+	if x == 0 || y == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[x])+int(gfLog[y])]
+}
+
+func gfDiv(x, y byte) byte {
+	if x == 0 {
+		return 0
+	}
+
+	return gfExp[(int(gfLog[x])+255-int(gfLog[y]))%255]
+}
+
+func gfPow(x byte, power int) byte {
+	e := (int(gfLog[x]) * power) % 255
+	if e < 0 {
+		e += 255
+	}
+
+	return gfExp[e]
+}
+
+func gfInverse(x byte) byte {
+	return gfExp[255-int(gfLog[x])]
+}
+
+// the polynomials are represented with the coefficients of the highest degree first
+
+func polyScale(p []byte, x byte) []byte {
+	r := make([]byte, len(p))
+	for i := range p {
+		r[i] = gfMul(p[i], x)
+	}
+
+	return r
+}
+
+func polyAdd(p, q []byte) []byte {
+	size := len(p)
+	if len(q) > size {
+		size = len(q)
+	}
+
+	r := make([]byte, size)
+	for i := range p {
+		r[i+size-len(p)] = p[i]
+	}
+
+	for i := range q {
+		r[i+size-len(q)] ^= q[i]
+	}
+
+	return r
+}
+
+func polyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+
+	for j := range q {
+		for i := range p {
+			r[i+j] ^= gfMul(p[i], q[j])
+		}
+	}
+
+	return r
+}
+
+func polyEval(p []byte, x byte) byte {
+	y := p[0]
+	for i := 1; i < len(p); i++ {
+		y = gfMul(y, x) ^ p[i]
+	}
+
+	return y
+}
+
+// polyMod returns the remainder of the division by a monic divisor.
+func polyMod(dividend, divisor []byte) []byte {
+	out := append([]byte{}, dividend...)
+
+	for i := 0; i < len(dividend)-(len(divisor)-1); i++ {
+		coef := out[i]
+		if coef == 0 {
+			continue
+		}
+
+		for j := 1; j < len(divisor); j++ {
+			if divisor[j] != 0 {
+				out[i+j] ^= gfMul(divisor[j], coef)
+			}
+		}
+	}
+
+	return out[len(out)-(len(divisor)-1):]
+}
+
+func generator(parity int) []byte {
+	g := []byte{1}
+	for i := 0; i < parity; i++ {
+		g = polyMul(g, []byte{1, gfPow(2, i)})
+	}
+
+	return g
+}
+
+// Encode returns the codeword of the data: the data followed by the parity bytes.
+// Up to parity/2 corrupted bytes of the codeword can be corrected by Decode.
+func Encode(data []byte, parity int) ([]byte, error) {
+	if parity <= 0 || len(data)+parity > MaxBlockSize {
+		return nil, errors.Errorf("invalid block size: %d data bytes, %d parity bytes", len(data), parity)
+	}
+
+	gen := generator(parity)
+	out := make([]byte, len(data)+parity)
+	copy(out, data)
+
+	for i := range data {
+		coef := out[i]
+		if coef == 0 {
+			continue
+		}
+
+		for j := 1; j < len(gen); j++ {
+			out[i+j] ^= gfMul(gen[j], coef)
+		}
+	}
+
+	copy(out, data)
+
+	return out, nil
+}
+
+// Decode corrects the codeword returning the data and the number of corrected bytes.
+func Decode(codeword []byte, parity int) ([]byte, int, error) {
+	if parity <= 0 || len(codeword) <= parity || len(codeword) > MaxBlockSize {
+		return nil, 0, errors.Errorf("invalid block size: %d bytes, %d parity bytes", len(codeword), parity)
+	}
+
+	msg := append([]byte{}, codeword...)
+
+	synd := syndromes(msg, parity)
+	if isZero(synd) {
+		return msg[:len(msg)-parity], 0, nil
+	}
+
+	errLoc, err := errorLocator(synd, parity)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	errPos, err := findErrors(reverse(errLoc), len(msg))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg = correctErrata(msg, synd, errPos)
+
+	if !isZero(syndromes(msg, parity)) {
+		return nil, 0, ErrTooManyErrors
+	}
+
+	return msg[:len(msg)-parity], len(errPos), nil
+}
+
+// syndromes returns the syndromes of the message, prefixed by a zero.
+func syndromes(msg []byte, parity int) []byte {
+	synd := make([]byte, parity+1)
+	for i := 0; i < parity; i++ {
+		synd[i+1] = polyEval(msg, gfPow(2, i))
+	}
+
+	return synd
+}
+
+// errorLocator finds the error locator polynomial with the Berlekamp-Massey algorithm.
+func errorLocator(synd []byte, parity int) ([]byte, error) {
+	errLoc := []byte{1}
+	oldLoc := []byte{1}
+	shift := len(synd) - parity
+
+	for i := 0; i < parity; i++ {
+		k := i + shift
+
+		delta := synd[k]
+		for j := 1; j < len(errLoc); j++ {
+			delta ^= gfMul(errLoc[len(errLoc)-(j+1)], synd[k-j])
+		}
+
+		oldLoc = append(oldLoc, 0)
+
+		if delta != 0 {
+			if len(oldLoc) > len(errLoc) {
+				newLoc := polyScale(oldLoc, delta)
+				oldLoc = polyScale(errLoc, gfInverse(delta))
+				errLoc = newLoc
+			}
+
+			errLoc = polyAdd(errLoc, polyScale(oldLoc, delta))
+		}
+	}
+
+	for len(errLoc) > 0 && errLoc[0] == 0 {
+		errLoc = errLoc[1:]
+	}
+
+	if (len(errLoc)-1)*2 > parity {
+		return nil, ErrTooManyErrors
+	}
+
+	return errLoc, nil
+}
+
+// findErrors finds the positions of the errors evaluating the error locator in every position (Chien search).
+func findErrors(errLoc []byte, size int) ([]int, error) {
+	errs := len(errLoc) - 1
+	positions := []int{}
+
+	for i := 0; i < size; i++ {
+		if polyEval(errLoc, gfPow(2, i)) == 0 {
+			positions = append(positions, size-1-i)
+		}
+	}
+
+	if len(positions) != errs {
+		return nil, ErrTooManyErrors
+	}
+
+	return positions, nil
+}
+
+// correctErrata computes the magnitude of the errors with the Forney algorithm, and corrects them.
+func correctErrata(msg, synd []byte, errPos []int) []byte {
+	coefPos := make([]int, len(errPos))
+	for i, p := range errPos {
+		coefPos[i] = len(msg) - 1 - p
+	}
+
+	// errata locator
+	errLoc := []byte{1}
+	for _, p := range coefPos {
+		errLoc = polyMul(errLoc, polyAdd([]byte{1}, []byte{gfPow(2, p), 0}))
+	}
+
+	// error evaluator
+	divisor := make([]byte, len(errLoc)+1)
+	divisor[0] = 1
+	errEval := reverse(polyMod(polyMul(reverse(synd), errLoc), divisor))
+
+	x := make([]byte, len(coefPos))
+	for i, p := range coefPos {
+		x[i] = gfPow(2, p-255)
+	}
+
+	e := make([]byte, len(msg))
+
+	for i, xi := range x {
+		xiInv := gfInverse(xi)
+
+		errLocPrime := byte(1)
+		for j := range x {
+			if j != i {
+				errLocPrime = gfMul(errLocPrime, 1^gfMul(xiInv, x[j]))
+			}
+		}
+
+		y := gfMul(xi, polyEval(reverse(errEval), xiInv))
+		e[errPos[i]] = gfDiv(y, errLocPrime)
+	}
+
+	return polyAdd(msg, e)
+}
+
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func reverse(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i := range p {
+		r[len(p)-1-i] = p[i]
+	}
+
+	return r
+}
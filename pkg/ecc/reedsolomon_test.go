@@ -0,0 +1,74 @@
+package ecc_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/ecc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Decode(t *testing.T) {
+	data := []byte("a partial key hidden in an image")
+
+	codeword, err := ecc.Encode(data, 16)
+	require.NoError(t, err)
+	require.Len(t, codeword, len(data)+16)
+	assert.Equal(t, data, codeword[:len(data)])
+
+	tt := []struct {
+		name      string
+		corrupt   []int
+		corrected int
+		wantErr   bool
+	}{
+		{name: "no errors"},
+		{name: "one error", corrupt: []int{0}, corrected: 1},
+		{name: "errors in data and parity", corrupt: []int{3, 10, 20, 40, 45}, corrected: 5},
+		{name: "max errors", corrupt: []int{0, 1, 2, 3, 4, 5, 6, 7}, corrected: 8},
+		{name: "too many errors", corrupt: []int{0, 1, 2, 3, 4, 5, 6, 7, 8}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			damaged := append([]byte{}, codeword...)
+			for _, i := range tc.corrupt {
+				damaged[i] ^= 0x5a
+			}
+
+			decoded, corrected, err := ecc.Decode(damaged, 16)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data, decoded)
+			assert.Equal(t, tc.corrected, corrected)
+		})
+	}
+}
+
+func Test_DecodeBlocks(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 60)
+
+	codeword, err := ecc.EncodeBlocks(data, 32)
+	require.NoError(t, err)
+	// 600 bytes are split in 3 blocks
+	require.Len(t, codeword, 600+3*32)
+	assert.Equal(t, ecc.BlocksSize(len(data), 32), len(codeword))
+
+	// a burst of errors is spread across the blocks by the interleaving
+	for i := 100; i < 140; i++ {
+		codeword[i] = 0
+	}
+
+	decoded, corrected, err := ecc.DecodeBlocks(codeword, len(data), 32)
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+	assert.Positive(t, corrected)
+
+	_, _, err = ecc.DecodeBlocks(codeword[1:], len(data), 32)
+	require.Error(t, err)
+}
@@ -5,28 +5,26 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/enrichman/stegosecrets/internal/log"
+	"github.com/enrichman/stegosecrets/pkg/armor"
+	"github.com/enrichman/stegosecrets/pkg/mnemonic"
+	"github.com/enrichman/stegosecrets/pkg/qr"
 	"github.com/pkg/errors"
 )
 
 func WriteFileChecksum(logger log.Logger, filename string) error {
-	fileInput, err := os.Open(filename)
+	h, err := HashFile(filename)
 	if err != nil {
-		return errors.Wrapf(err, "failed opening file '%s'", filename)
-	}
-	defer fileInput.Close()
-
-	content, err := io.ReadAll(fileInput)
-	if err != nil {
-		return errors.Wrapf(err, "failed reading file content '%s'", filename)
+		return err
 	}
 
-	return WriteChecksum(logger, content, filename)
+	return WriteHashChecksum(logger, h, filename)
 }
 
 func WriteChecksum(logger log.Logger, content []byte, filename string) error {
@@ -37,6 +35,12 @@ func WriteChecksum(logger log.Logger, content []byte, filename string) error {
 		return errors.Wrapf(err, "failed hashing content of '%s' file", filename)
 	}
 
+	return WriteHashChecksum(logger, h, filename)
+}
+
+// WriteHashChecksum writes the checksum file of filename from the hash of its content,
+// useful when the content was hashed while streaming.
+func WriteHashChecksum(logger log.Logger, h hash.Hash, filename string) error {
 	checksum := fmt.Sprintf("%x\t%s", h.Sum(nil), filepath.Base(filename))
 
 	return WriteFile(logger, []byte(checksum), filename+".checksum")
@@ -48,6 +52,67 @@ func WriteKey(logger log.Logger, key []byte, filename string) error {
 	return WriteFile(logger, []byte(base64EncodedKey), filename+".key")
 }
 
+// WriteArmoredKey writes the key as an ASCII armored block (see armor.Encode) into the file with the .key extension.
+func WriteArmoredKey(logger log.Logger, key []byte, filename string, headers map[string]string) error {
+	content, err := armor.Marshal(armor.TypePartialKey, headers, key)
+	if err != nil {
+		return errors.Wrap(err, "failed armoring key")
+	}
+
+	return WriteFile(logger, content, filename+".key")
+}
+
+// mnemonicWordsPerLine is the number of words of every line of the mnemonic key files
+const mnemonicWordsPerLine = 6
+
+// WriteMnemonicKey writes the key as a list of words (see mnemonic.Encode) into the file with the .key extension.
+func WriteMnemonicKey(logger log.Logger, key []byte, filename string) error {
+	words, err := mnemonic.Encode(key)
+	if err != nil {
+		return errors.Wrap(err, "failed encoding key to mnemonic")
+	}
+
+	content := &strings.Builder{}
+	for i := 0; i < len(words); i += mnemonicWordsPerLine {
+		line := words[i:min(i+mnemonicWordsPerLine, len(words))]
+		content.WriteString(strings.Join(line, " ") + "\n")
+	}
+
+	return WriteFile(logger, []byte(content.String()), filename+".key")
+}
+
+// The extensions of the QR codes of the keys.
+const (
+	QRExtension    = ".qr.png"
+	QRSVGExtension = ".qr.svg"
+)
+
+// WriteQRKey writes the key (base64 encoded, as in the .key file) as a QR code into the file
+// with the .qr.png extension, and also into the .qr.svg file if svg is true.
+func WriteQRKey(logger log.Logger, key []byte, filename string, svg bool) error {
+	base64EncodedKey := []byte(base64.StdEncoding.EncodeToString(key))
+
+	png, err := qr.EncodePNG(base64EncodedKey)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteFile(logger, png, filename+QRExtension); err != nil {
+		return err
+	}
+
+	if !svg {
+		return nil
+	}
+
+	content, err := qr.EncodeSVG(base64EncodedKey)
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(logger, content, filename+QRSVGExtension)
+}
+
 func WriteFile(logger log.Logger, content []byte, filename string) error {
 	f, err := os.Create(filename)
 	if err != nil {
@@ -86,44 +151,116 @@ func ReadFile(filename string) ([]byte, error) {
 	return bb, nil
 }
 
+// ReadKey reads a key encoded in base64, as a mnemonic (see WriteMnemonicKey) or as an armored block.
 func ReadKey(filename string) ([]byte, error) {
 	encodedKey, err := ReadFile(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed reading key file '%s'", filename)
 	}
 
-	decodedKey, err := base64.StdEncoding.DecodeString(string(encodedKey))
+	decodedKey, err := DecodeKey(encodedKey)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed decoding file '%s' from base64", filename)
+		return nil, errors.Wrapf(err, "failed decoding key file '%s'", filename)
 	}
 
 	return decodedKey, nil
 }
 
-func Check(filename, checksumFilename string) error {
-	content, err := ReadFile(filename)
+// ReadQRKey reads a key from the QR code in the image file (see WriteQRKey).
+func ReadQRKey(filename string) ([]byte, error) {
+	encodedKey, err := qr.DecodeFile(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	h := sha256.New()
+	decodedKey, err := DecodeKey(encodedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed decoding QR code of '%s'", filename)
+	}
+
+	return decodedKey, nil
+}
+
+// DecodeKey decodes a key encoded in base64, as a mnemonic or as an armored block.
+func DecodeKey(encodedKey []byte) ([]byte, error) {
+	if armor.IsArmored(encodedKey) {
+		block, decodedKey, err := armor.Unmarshal(encodedKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed decoding armored key")
+		}
+
+		if block.Type != armor.TypePartialKey {
+			return nil, errors.Errorf("unexpected armored block '%s'", block.Type)
+		}
+
+		return decodedKey, nil
+	}
+
+	if mnemonic.IsMnemonic(encodedKey) {
+		decodedKey, err := mnemonic.Decode(strings.Fields(string(encodedKey)))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed decoding mnemonic")
+		}
 
-	_, err = h.Write(content)
+		return decodedKey, nil
+	}
+
+	decodedKey, err := base64.StdEncoding.DecodeString(string(encodedKey))
 	if err != nil {
-		return errors.Wrapf(err, "failed hashing content of '%s' file", filename)
+		return nil, errors.Wrap(err, "failed decoding base64")
+	}
+
+	return decodedKey, nil
+}
+
+// ErrChecksumMismatch is returned when the content does not match the checksum file.
+var ErrChecksumMismatch = errors.New("failed checksum verification")
+
+func Check(filename, checksumFilename string) error {
+	h, err := HashFile(filename)
+	if err != nil {
+		return err
 	}
 
+	return CheckHash(h, checksumFilename)
+}
+
+// CheckHash verifies the hash of a content against the checksum file.
+func CheckHash(h hash.Hash, checksumFilename string) error {
 	checksumFileContent, err := ReadFile(checksumFilename)
 	if err != nil {
 		return err
 	}
 
-	checksumToVerify := strings.Split(string(checksumFileContent), "\t")[0]
+	// the checksum is the first field, followed by the filename (tab separated, or spaces if created by sha256sum)
+	fields := strings.Fields(string(checksumFileContent))
+	if len(fields) == 0 {
+		return errors.Errorf("empty checksum file '%s'", checksumFilename)
+	}
+
 	checksumContent := hex.EncodeToString(h.Sum(nil))
 
-	if checksumToVerify != checksumContent {
-		return errors.New("failed checksum verification")
+	if !strings.EqualFold(fields[0], checksumContent) {
+		return ErrChecksumMismatch
 	}
 
 	return nil
 }
+
+// HashFile returns the sha256 hash of the file content.
+func HashFile(filename string) (hash.Hash, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed hashing content of '%s' file", filename)
+	}
+
+	return h, nil
+}
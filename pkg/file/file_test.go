@@ -38,3 +38,38 @@ func Test_WriteKey(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expectedKey, key)
 }
+
+func Test_Check(t *testing.T) {
+	tmpDir := t.TempDir()
+	original := path.Join(tmpDir, "file")
+
+	err := os.WriteFile(original, []byte("content"), 0o600)
+	require.NoError(t, err)
+
+	// checksum created by sha256sum
+	checksum := []byte("ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73  file\n")
+	err = os.WriteFile(original+".checksum", checksum, 0o600)
+	require.NoError(t, err)
+
+	err = file.Check(original, original+".checksum")
+	require.NoError(t, err)
+
+	err = os.WriteFile(original, []byte("modified content"), 0o600)
+	require.NoError(t, err)
+
+	err = file.Check(original, original+".checksum")
+	require.ErrorIs(t, err, file.ErrChecksumMismatch)
+}
+
+func Test_WriteMnemonicKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyFile := path.Join(tmpDir, "file")
+
+	expectedKey := []byte("a partial key longer than a line of words")
+	err := file.WriteMnemonicKey(nil, expectedKey, keyFile)
+	require.NoError(t, err)
+
+	key, err := file.ReadKey(keyFile + ".key")
+	require.NoError(t, err)
+	require.Equal(t, expectedKey, key)
+}
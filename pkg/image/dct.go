@@ -0,0 +1,324 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// dctQuality is the quality of the JPEG images written by the DCT backend
+	dctQuality = 95
+	// dctStep is the quantization step of the coefficients hiding the data, written in the quantization table
+	// of the luminance: the data survives as long as the JPEG (re)compression changes them by less than half of it
+	dctStep = 48
+	// dctRepeat is the number of copies of every bit, spread across the image and decoded by majority
+	dctRepeat = 3
+	// dctCheckQuality is the quality of the recompression done by Embed to check that the data survives it
+	dctCheckQuality = 75
+)
+
+// dctCoefficients are the low frequency coefficients (natural order indexes, row v and column u at v*8+u)
+// of every 8x8 block of the luminance used to hide the data: they are finely quantized by JPEG encoders,
+// and their changes are not very visible.
+var dctCoefficients = []int{1*8 + 1, 1*8 + 2, 2*8 + 1, 2*8 + 2}
+
+// dctCos[x][u] = cos((2x+1)uπ/16)
+var dctCos = func() (c [8][8]float64) {
+	for x := 0; x < 8; x++ {
+		for u := 0; u < 8; u++ {
+			c[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+		}
+	}
+
+	return c
+}()
+
+func init() {
+	Register(&DCT{})
+}
+
+// DCT hides the data in the quantized DCT coefficients of the luminance of a JPEG image, like JSteg:
+// every bit is the least significant bit of a quantized coefficient, written in the JPEG file.
+//
+// Unlike JSteg, that uses all the non-zero coefficients quantized with the tables of the encoder,
+// only a few low frequency coefficients of every 8x8 block are used, and they are quantized with
+// a coarse step (dctStep) written in the quantization table of the image. When the image is
+// recompressed with a lower quality the coefficients are quantized again with finer steps, so the
+// hidden bits are read again by dequantizing them with the table of the recompressed image,
+// and quantizing them with the coarse step. The trade-offs are:
+//   - the data survives the recompression of the image with a lower quality (JSteg and F5 do not), so
+//     the output can be shared as an ordinary photo;
+//   - the capacity is much lower (a few coefficients of every 8x8 block, and every bit is repeated),
+//     so bigger images are needed;
+//   - the coarse steps in the quantization table, and the changes of the coefficients, larger than
+//     the ±1 of the finely quantized coefficients of JSteg and F5, make the image easier to detect.
+type DCT struct{}
+
+func (*DCT) Name() string {
+	return "dct"
+}
+
+func (*DCT) ID() byte {
+	return 2
+}
+
+// SupportedFormats returns JPEG: the data is read from the coefficients of the JPEG file.
+func (*DCT) SupportedFormats() []string {
+	return []string{"jpeg"}
+}
+
+func (*DCT) Capacity(width, height int) int {
+	available := dctSlots(width, height)/dctRepeat/8 - sizeHeaderLen
+	if available < 0 {
+		return 0
+	}
+
+	return available
+}
+
+// dctSlots returns the number of coefficients available to hide the bits (only the full blocks are used).
+func dctSlots(width, height int) int {
+	return (width / 8) * (height / 8) * len(dctCoefficients)
+}
+
+// dctSlot returns the block (column and row) and the natural order index of the coefficient of the slot.
+func dctSlot(width, slot int) (int, int, int) {
+	block := slot / len(dctCoefficients)
+	blocksPerRow := width / 8
+
+	return block % blocksPerRow, block / blocksPerRow, dctCoefficients[slot%len(dctCoefficients)]
+}
+
+func (d *DCT) Embed(data []byte, cover image.Image, w io.Writer) error {
+	bounds := cover.Bounds()
+	if available := d.Capacity(bounds.Dx(), bounds.Dy()); len(data) > available {
+		return &CapacityError{Required: len(data), Available: available}
+	}
+
+	payload := make([]byte, sizeHeaderLen, sizeHeaderLen+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	payload = append(payload, data...)
+
+	quant := quantTables(dctQuality)
+	for _, n := range dctCoefficients {
+		quant[0][n] = dctStep
+	}
+
+	planes := newYCbCrPlanes(cover)
+	components := [3]*jpegBlocks{}
+	luma := planes.transform(0)
+
+	components[0] = planes.quantize(luma, quant[0])
+	components[1] = planes.quantize(planes.transform(1), quant[1])
+	components[2] = planes.quantize(planes.transform(2), quant[1])
+
+	bits := len(payload) * 8
+	stride := dctSlots(bounds.Dx(), bounds.Dy()) / dctRepeat
+
+	for i := 0; i < bits; i++ {
+		bit := payload[i/8] >> (7 - i%8) & 1
+
+		for r := 0; r < dctRepeat; r++ {
+			bx, by, n := dctSlot(bounds.Dx(), r*stride+i)
+			block := components[0].block(bx, by)
+
+			if byte(block[n]&1) == bit {
+				continue
+			}
+
+			// the coefficient is rounded to the other side of its real value, to change it as little as possible
+			if luma[by*planes.blocksPerRow+bx][n]/dctStep > float64(block[n]) {
+				block[n]++
+			} else {
+				block[n]--
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeJPEG(buf, bounds.Dx(), bounds.Dy(), components, quant); err != nil {
+		return err
+	}
+
+	// the image is recompressed to check that the data survives the clipping of the pixels
+	if !survivesRecompression(d, buf.Bytes(), data) {
+		return errors.New("failed hiding data into the image: the cover image is not suitable (too bright or too dark)")
+	}
+
+	_, err := buf.WriteTo(w)
+
+	return errors.Wrap(err, "failed writing out image")
+}
+
+func survivesRecompression(d *DCT, content, data []byte) bool {
+	img, err := jpeg.Decode(bytes.NewReader(content))
+	if err != nil {
+		return false
+	}
+
+	recompressed := &bytes.Buffer{}
+	if err := jpeg.Encode(recompressed, img, &jpeg.Options{Quality: dctCheckQuality}); err != nil {
+		return false
+	}
+
+	for _, r := range []io.Reader{bytes.NewReader(content), recompressed} {
+		extracted, err := d.Extract(r)
+		if err != nil || !bytes.Equal(extracted, data) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *DCT) Extract(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading image")
+	}
+
+	luma, width, height, quant, err := readJPEGLuma(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed decoding 'jpeg' image")
+	}
+
+	if d.Capacity(width, height) == 0 {
+		return nil, errors.New("image too small")
+	}
+
+	stride := dctSlots(width, height) / dctRepeat
+
+	// the bit is read from the coefficient dequantized with the table of the image and quantized with the step
+	extract := func(slot int) int {
+		bx, by, n := dctSlot(width, slot)
+		block := luma.block(bx, by)
+
+		return int(math.Round(float64(block[n])*float64(quant[n])/dctStep)) & 1
+	}
+
+	readBytes := func(offset, n int) []byte {
+		out := make([]byte, n)
+
+		for i := 0; i < n*8; i++ {
+			votes := 0
+			for r := 0; r < dctRepeat; r++ {
+				votes += extract(r*stride + offset*8 + i)
+			}
+
+			if votes*2 > dctRepeat {
+				out[i/8] |= 1 << (7 - i%8)
+			}
+		}
+
+		return out
+	}
+
+	size := binary.BigEndian.Uint32(readBytes(0, sizeHeaderLen))
+	if available := d.Capacity(width, height); int64(size) > int64(available) {
+		return nil, errors.Errorf("invalid size of the hidden data: %d bytes, %d available", size, available)
+	}
+
+	return readBytes(sizeHeaderLen, int(size)), nil
+}
+
+// ycbcrPlanes holds the Y, Cb and Cr components of the image (JFIF conversion), level shifted by -128.
+// The size is rounded up to full 8x8 blocks, replicating the last row and column.
+type ycbcrPlanes struct {
+	blocksPerRow, rows int
+	planes             [3][]float64
+}
+
+func newYCbCrPlanes(img image.Image) *ycbcrPlanes {
+	bounds := img.Bounds()
+	p := &ycbcrPlanes{
+		blocksPerRow: (bounds.Dx() + 7) / 8,
+		rows:         (bounds.Dy() + 7) / 8,
+	}
+
+	width, height := p.blocksPerRow*8, p.rows*8
+	for c := range p.planes {
+		p.planes[c] = make([]float64, width*height)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px := bounds.Min.X + min(x, bounds.Dx()-1)
+			py := bounds.Min.Y + min(y, bounds.Dy()-1)
+
+			c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+
+			i := y*width + x
+			p.planes[0][i] = 0.299*r + 0.587*g + 0.114*b - 128
+			p.planes[1][i] = -0.168736*r - 0.331264*g + 0.5*b
+			p.planes[2][i] = 0.5*r - 0.418688*g - 0.081312*b
+		}
+	}
+
+	return p
+}
+
+// transform returns the DCT coefficients of the blocks of the component, block by block (row-major).
+func (p *ycbcrPlanes) transform(component int) [][64]float64 {
+	blocks := make([][64]float64, p.blocksPerRow*p.rows)
+	plane := p.planes[component]
+	width := p.blocksPerRow * 8
+
+	for by := 0; by < p.rows; by++ {
+		for bx := 0; bx < p.blocksPerRow; bx++ {
+			// the transform is separable: the rows first, then the columns
+			rows := [8][8]float64{}
+
+			for y := 0; y < 8; y++ {
+				for u := 0; u < 8; u++ {
+					for x := 0; x < 8; x++ {
+						rows[y][u] += plane[(by*8+y)*width+bx*8+x] * dctCos[x][u]
+					}
+				}
+			}
+
+			block := &blocks[by*p.blocksPerRow+bx]
+
+			for v := 0; v < 8; v++ {
+				for u := 0; u < 8; u++ {
+					sum := 0.0
+					for y := 0; y < 8; y++ {
+						sum += rows[y][u] * dctCos[y][v]
+					}
+
+					block[v*8+u] = sum * dctScale(u) * dctScale(v) / 4
+				}
+			}
+		}
+	}
+
+	return blocks
+}
+
+// quantize returns the coefficients quantized with the table.
+func (p *ycbcrPlanes) quantize(coefficients [][64]float64, table [64]int) *jpegBlocks {
+	blocks := newJPEGBlocks(p.blocksPerRow, p.rows)
+
+	for i, block := range coefficients {
+		for n, coefficient := range block {
+			blocks.blocks[i][n] = int32(math.Round(coefficient / float64(table[n])))
+		}
+	}
+
+	return blocks
+}
+
+func dctScale(u int) float64 {
+	if u == 0 {
+		return math.Sqrt2 / 2
+	}
+
+	return 1
+}
@@ -0,0 +1,122 @@
+package image_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	stegoimage "github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPhoto returns a JPEG image with gradients and some noise, similar to a photo.
+func newPhoto(t *testing.T, width, height int) *bytes.Buffer {
+	t.Helper()
+
+	rnd := rand.New(rand.NewSource(42))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			noise := rnd.Intn(16)
+			img.Set(x, y, color.RGBA{
+				R: uint8(40 + x*160/width + noise),
+				G: uint8(60 + y*140/height + noise),
+				B: uint8(100 + (x+y)*60/(width+height) + noise),
+				A: 0xff,
+			})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}))
+
+	return buf
+}
+
+func TestDCT_Recompression(t *testing.T) {
+	backend, err := stegoimage.Backend("dct")
+	require.NoError(t, err)
+	assert.Equal(t, ".jpg", stegoimage.Extension(backend))
+
+	secret := []byte("a partial key that should survive the recompression")
+
+	var imageOut bytes.Buffer
+	err = stegoimage.EncodeSecretWith(backend, secret, newPhoto(t, 320, 240), &imageOut)
+	require.NoError(t, err)
+
+	// the coefficients are written in a valid JPEG image
+	img, format, err := image.Decode(bytes.NewReader(imageOut.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 320, 240), img.Bounds())
+
+	out, detected, err := stegoimage.DecodeSecretBackend(bytes.NewReader(imageOut.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, secret, out)
+	assert.Equal(t, "dct", detected.Name())
+
+	// the image is recompressed with a lower quality, as done by chat apps
+	for _, quality := range []int{85, 75, 60, 40} {
+		img, err := jpeg.Decode(bytes.NewReader(imageOut.Bytes()))
+		require.NoError(t, err)
+
+		var recompressed bytes.Buffer
+		require.NoError(t, jpeg.Encode(&recompressed, img, &jpeg.Options{Quality: quality}))
+
+		out, err := stegoimage.DecodeSecret(&recompressed)
+		require.NoError(t, err, "quality %d", quality)
+		assert.Equal(t, secret, out, "quality %d", quality)
+	}
+}
+
+func TestDCT_ImageTooSmall(t *testing.T) {
+	backend, err := stegoimage.Backend("dct")
+	require.NoError(t, err)
+
+	err = stegoimage.EncodeSecretWith(backend, make([]byte, 64), newPhoto(t, 64, 64), &bytes.Buffer{})
+
+	var capacityErr *stegoimage.CapacityError
+	require.ErrorAs(t, err, &capacityErr)
+}
+
+func TestDCT_OddSize(t *testing.T) {
+	backend, err := stegoimage.Backend("dct")
+	require.NoError(t, err)
+
+	// the partial blocks on the right and bottom edges are written, but not used to hide the data
+	var imageOut bytes.Buffer
+	err = stegoimage.EncodeSecretWith(backend, []byte("test secret"), newPhoto(t, 245, 203), &imageOut)
+	require.NoError(t, err)
+
+	img, err := jpeg.Decode(bytes.NewReader(imageOut.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 245, 203), img.Bounds())
+
+	out, err := stegoimage.DecodeSecret(bytes.NewReader(imageOut.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test secret"), out)
+}
+
+func TestDCT_Extract(t *testing.T) {
+	backend, err := stegoimage.Backend("dct")
+	require.NoError(t, err)
+
+	// a photo without hidden data (subsampled chrominance)
+	_, err = stegoimage.DecodeSecret(newPhoto(t, 320, 240))
+	require.Error(t, err)
+
+	photo := newPhoto(t, 320, 240).Bytes()
+
+	// truncated image
+	_, err = backend.Extract(bytes.NewReader(photo[:len(photo)/2]))
+	require.Error(t, err)
+
+	// not a JPEG image
+	_, err = backend.Extract(newPNG(t, 64, 64))
+	require.Error(t, err)
+}
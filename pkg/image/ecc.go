@@ -0,0 +1,129 @@
+package image
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/enrichman/stegosecrets/pkg/ecc"
+	"github.com/pkg/errors"
+)
+
+// The payload embedded in the images is protected by a Reed-Solomon code, so a few changed pixels
+// are corrected, and by a CRC, so the damage that cannot be corrected is detected.
+// The frame starts with a small header (magic and size of the payload) repeated and decoded by majority,
+// followed by the interleaved Reed-Solomon blocks of the payload and its CRC.
+// The frame is found again in the images cropped at the edges by the LSB backend, that hides it inside
+// a margin after a synchronization word: the other backends read it from the position where it was embedded.
+const (
+	// eccParity is the number of parity bytes of every block: up to half of them can be corrected
+	eccParity = 32
+	// eccMagic distinguishes the frames from the payloads embedded by older releases
+	eccMagic        byte = 'E'
+	eccHeaderCopies      = 3
+	eccHeaderLen         = (1 + 4) * eccHeaderCopies
+	crcLen               = 4
+)
+
+// ErrDamaged is returned when the data hidden in the image is too damaged to be recovered.
+var ErrDamaged = errors.New("the hidden data is damaged beyond repair")
+
+// frameSize returns the size of the frame of a payload of the given size.
+func frameSize(payloadLen int) int {
+	return eccHeaderLen + ecc.BlocksSize(payloadLen+crcLen, eccParity)
+}
+
+// maxPayload returns the maximum size of a payload fitting in a frame of the given size.
+func maxPayload(available int) int {
+	body := available - eccHeaderLen
+	blocks := (body + ecc.MaxBlockSize - 1) / ecc.MaxBlockSize
+
+	payload := body - blocks*eccParity - crcLen
+	for payload > 0 && frameSize(payload) > available {
+		payload--
+	}
+
+	if payload < 0 {
+		return 0
+	}
+
+	return payload
+}
+
+// frame protects the payload with the error correcting code.
+func frame(payload []byte) ([]byte, error) {
+	header := []byte{eccMagic}
+	header = binary.BigEndian.AppendUint32(header, uint32(len(payload)))
+
+	body, err := ecc.EncodeBlocks(binary.BigEndian.AppendUint32(append([]byte{}, payload...), crc32.ChecksumIEEE(payload)), eccParity)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed encoding error correcting code")
+	}
+
+	out := make([]byte, 0, frameSize(len(payload)))
+	for i := 0; i < eccHeaderCopies; i++ {
+		out = append(out, header...)
+	}
+
+	return append(out, body...), nil
+}
+
+// unframe returns the payload of the frame, correcting the errors. It returns false if the data is not
+// a frame, i.e. it was embedded by an older release, and ErrDamaged if the errors cannot be corrected.
+func unframe(data []byte) ([]byte, bool, error) {
+	if len(data) < eccHeaderLen {
+		return nil, false, nil
+	}
+
+	header := majority(data[:eccHeaderLen], eccHeaderCopies)
+	if header[0] != eccMagic {
+		return nil, false, nil
+	}
+
+	payloadLen := int(binary.BigEndian.Uint32(header[1:]))
+	size := ecc.BlocksSize(payloadLen+crcLen, eccParity)
+	body := data[eccHeaderLen:]
+
+	// the bytes missing at the end (i.e. the size of the data read by the backend was damaged) are decoded
+	// as errors, as long as they can be corrected
+	if missing := size - len(body); missing > 0 {
+		blocks := (size + ecc.MaxBlockSize - 1) / ecc.MaxBlockSize
+		if missing > blocks*eccParity/2 {
+			return nil, true, errors.Wrapf(ErrDamaged, "%d bytes missing", missing)
+		}
+
+		body = append(append([]byte{}, body...), make([]byte, missing)...)
+	}
+
+	decoded, _, err := ecc.DecodeBlocks(body[:size], payloadLen+crcLen, eccParity)
+	if err != nil {
+		return nil, true, errors.Wrap(ErrDamaged, err.Error())
+	}
+
+	payload, checksum := decoded[:payloadLen], decoded[payloadLen:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(checksum) {
+		return nil, true, errors.Wrap(ErrDamaged, "checksum mismatch")
+	}
+
+	return payload, true, nil
+}
+
+// majority returns the bitwise majority of the copies of the data.
+func majority(data []byte, copies int) []byte {
+	size := len(data) / copies
+	out := make([]byte, size)
+
+	for i := 0; i < size; i++ {
+		for bit := 0; bit < 8; bit++ {
+			votes := 0
+			for c := 0; c < copies; c++ {
+				votes += int(data[c*size+i] >> bit & 1)
+			}
+
+			if votes*2 > copies {
+				out[i] |= 1 << bit
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,134 @@
+package image_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"testing"
+
+	stegoimage "github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeSecret_DamagedImage(t *testing.T) {
+	secret := []byte("test secret")
+
+	var imageOut bytes.Buffer
+	err := stegoimage.EncodeSecret(secret, newPNG(t, 64, 64), &imageOut)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name    string
+		damage  func(img *image.NRGBA) image.Image
+		wantErr bool
+	}{
+		{
+			name: "a few changed pixels",
+			damage: func(img *image.NRGBA) image.Image {
+				// the LSB backend hides the data row by row, inside a margin of 3 pixels
+				for x := 3; x < 11; x++ {
+					img.Set(x, 4, color.White)
+				}
+
+				return img
+			},
+		},
+		{
+			name: "cropped right edge",
+			damage: func(img *image.NRGBA) image.Image {
+				img.Set(5, 5, color.White)
+
+				return img.SubImage(image.Rect(0, 0, 48, 64))
+			},
+		},
+		{
+			name: "cropped edges",
+			damage: func(img *image.NRGBA) image.Image {
+				img.Set(5, 5, color.White)
+
+				return img.SubImage(image.Rect(3, 2, 61, 63))
+			},
+		},
+		{
+			name: "too many changed pixels",
+			damage: func(img *image.NRGBA) image.Image {
+				// the header, in the first row, is left untouched
+				for y := 4; y < 64; y++ {
+					for x := 0; x < 64; x++ {
+						img.Set(x, y, color.White)
+					}
+				}
+
+				return img
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := png.Decode(bytes.NewReader(imageOut.Bytes()))
+			require.NoError(t, err)
+
+			img := image.NewNRGBA(decoded.Bounds())
+			draw.Draw(img, img.Bounds(), decoded, image.Point{}, draw.Src)
+
+			var damaged bytes.Buffer
+			require.NoError(t, png.Encode(&damaged, tc.damage(img)))
+
+			out, err := stegoimage.DecodeSecret(&damaged)
+			if tc.wantErr {
+				require.ErrorIs(t, err, stegoimage.ErrDamaged)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, secret, out)
+		})
+	}
+}
+
+// damagedPNG is a fake backend always reading a damaged frame, as another backend could read from the images.
+type damagedPNG struct{}
+
+func (damagedPNG) Name() string               { return "damaged-test" }
+func (damagedPNG) ID() byte                   { return 251 }
+func (damagedPNG) SupportedFormats() []string { return []string{"png"} }
+
+func (damagedPNG) Capacity(width, height int) int { return 0 }
+
+func (damagedPNG) Embed(data []byte, cover image.Image, w io.Writer) error {
+	return errors.New("not supported")
+}
+
+func (damagedPNG) Extract(r io.Reader) ([]byte, error) {
+	// the header (magic and size, three times) of a frame of 10 bytes, followed by a body failing the checksum
+	header := []byte{'E', 0, 0, 0, 10}
+	data := bytes.Repeat(header, 3)
+
+	return append(data, make([]byte, 10+4+32)...), nil
+}
+
+func init() {
+	stegoimage.Register(damagedPNG{})
+}
+
+func TestDecodeSecret_DamagedByOtherBackend(t *testing.T) {
+	secret := []byte("test secret")
+
+	var imageOut bytes.Buffer
+	err := stegoimage.EncodeSecret(secret, newPNG(t, 64, 64), &imageOut)
+	require.NoError(t, err)
+
+	// the damaged frame found by the first backend does not stop the detection, nor the legacy decoding
+	// (see TestDecodeSecret_Legacy)
+	out, detected, err := stegoimage.DecodeSecretBackend(&imageOut)
+	require.NoError(t, err)
+	assert.Equal(t, secret, out)
+	assert.Equal(t, stegoimage.DefaultBackend, detected.Name())
+}
@@ -3,61 +3,267 @@ package image
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"image"
 	_ "image/jpeg" // enable decoding for jpeg images.
 	_ "image/png"  // enable decoding for png images.
 	"io"
 	"os"
 
-	"github.com/auyer/steganography"
 	"github.com/pkg/errors"
 )
 
+// CapacityError is returned when the image is too small to hide the secret.
+type CapacityError struct {
+	Image     string
+	Required  int
+	Available int
+}
+
+func (e *CapacityError) Error() string {
+	name := "image"
+	if e.Image != "" {
+		name = fmt.Sprintf("image '%s'", e.Image)
+	}
+
+	return fmt.Sprintf("%s too small: %d bytes required, %d available", name, e.Required, e.Available)
+}
+
+// Capacity returns the number of bytes of a secret that can be hidden in the image with the default backend.
+func Capacity(img image.Image) int {
+	return CapacityWith(Default(), img.Bounds().Dx(), img.Bounds().Dy())
+}
+
+// CapacityWith returns the number of bytes of a secret that can be hidden by the backend
+// in an image of the given size, net of the marker and of the error correcting code.
+func CapacityWith(s Steganographer, width, height int) int {
+	available := maxPayload(s.Capacity(width, height)) - markerLen
+	if available < 0 {
+		return 0
+	}
+
+	return available
+}
+
+// CapacityFromFile returns the number of bytes that can be hidden in the image file by the backend,
+// reading only its header.
+func CapacityFromFile(s Steganographer, filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed decoding '%s' image config", format)
+	}
+
+	return CapacityWith(s, config.Width, config.Height), nil
+}
+
+// EncodeSecretFromFile hides the secret in the input image with the default backend.
 func EncodeSecretFromFile(secret []byte, inputFile, outputFile string) error {
+	return EncodeSecretFromFileWith(Default(), secret, inputFile, outputFile)
+}
+
+// EncodeSecretFromFileWith hides the secret in the input image with the backend.
+func EncodeSecretFromFileWith(s Steganographer, secret []byte, inputFile, outputFile string) error {
 	inputImageFile, err := os.Open(inputFile)
 	if err != nil {
 		return errors.Wrapf(err, "failed opening input file '%s'", inputFile)
 	}
 	defer inputImageFile.Close()
 
+	img, format, err := image.Decode(bufio.NewReader(inputImageFile))
+	if err != nil {
+		return errors.Wrapf(err, "failed decoding '%s' image", format)
+	}
+
+	if err := checkCapacity(s, inputFile, img, secret); err != nil {
+		return err
+	}
+
+	data, err := frame(withMarker(s, secret))
+	if err != nil {
+		return err
+	}
+
 	outputImageFile, err := os.Create(outputFile)
 	if err != nil {
 		return errors.Wrapf(err, "failed creating output file '%s'", outputFile)
 	}
 	defer outputImageFile.Close()
 
-	return EncodeSecret(secret, inputImageFile, outputImageFile)
+	return s.Embed(data, img, outputImageFile)
 }
 
+// EncodeSecret hides the secret in the image with the default backend.
 func EncodeSecret(secret []byte, imgIn io.Reader, imgOut io.Writer) error {
+	return EncodeSecretWith(Default(), secret, imgIn, imgOut)
+}
+
+// EncodeSecretWith hides the secret in the image with the backend.
+func EncodeSecretWith(s Steganographer, secret []byte, imgIn io.Reader, imgOut io.Writer) error {
 	img, format, err := image.Decode(bufio.NewReader(imgIn))
 	if err != nil {
 		return errors.Wrapf(err, "failed decoding '%s' image", format)
 	}
 
-	// TODO this should be checked with the secret to see if the image is big enough
-	// sizeOfMessage := steganography.MaxEncodeSize(img)
-
-	w := new(bytes.Buffer)
+	if err := checkCapacity(s, "", img, secret); err != nil {
+		return err
+	}
 
-	err = steganography.Encode(w, img, secret)
+	data, err := frame(withMarker(s, secret))
 	if err != nil {
-		return errors.Wrap(err, "failed encoding secret into image")
+		return err
 	}
 
-	_, err = w.WriteTo(imgOut)
+	return s.Embed(data, img, imgOut)
+}
+
+// checkCapacity returns a CapacityError if the secret does not fit in the image.
+func checkCapacity(s Steganographer, name string, img image.Image, secret []byte) error {
+	available := CapacityWith(s, img.Bounds().Dx(), img.Bounds().Dy())
+	if len(secret) > available {
+		return &CapacityError{Image: name, Required: len(secret), Available: available}
+	}
 
-	return errors.Wrap(err, "failed writing out image")
+	return nil
 }
 
+// DecodeSecret returns the secret hidden in the image, detecting the backend from the embedded marker.
+// Images created by older releases, without the marker, are decoded with the LSB backend.
+// It returns ErrDamaged if the image was modified beyond what the error correcting code can fix.
 func DecodeSecret(imgIn io.Reader) ([]byte, error) {
-	img, format, err := image.Decode(bufio.NewReader(imgIn))
+	secret, _, err := DecodeSecretBackend(imgIn)
+
+	return secret, err
+}
+
+// DecodeSecretBackend returns the secret hidden in the image along with the backend that hid it.
+// A damaged frame found by a backend is reported only if no other backend finds the secret.
+func DecodeSecretBackend(imgIn io.Reader) ([]byte, Steganographer, error) {
+	content, err := io.ReadAll(imgIn)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed decoding '%s' image", format)
+		return nil, nil, errors.Wrap(err, "failed reading image")
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed decoding '%s' image", format)
+	}
+
+	var (
+		extractErr error
+		damagedErr error
+		legacy     []byte
+	)
+
+	for _, s := range backendsForFormat(format) {
+		// the backends needing a key are used only by DecodeSecretWithKey
+		if _, keyed := s.(KeyedSteganographer); keyed {
+			continue
+		}
+
+		data, err := s.Extract(bytes.NewReader(content))
+		if err != nil {
+			extractErr = err
+
+			continue
+		}
+
+		// the data extracted by another backend can look like a damaged frame
+		secret, found, err := unframeMarker(s, data)
+		if err != nil {
+			damagedErr = err
+
+			continue
+		}
+
+		if found {
+			return secret, s, nil
+		}
+
+		// images created by older releases have no marker
+		if s.Name() == DefaultBackend {
+			legacy = data
+		}
+	}
+
+	if legacy != nil {
+		return legacy, Default(), nil
+	}
+
+	if damagedErr != nil {
+		return nil, nil, damagedErr
+	}
+
+	if extractErr != nil {
+		return nil, nil, extractErr
+	}
+
+	return nil, nil, errors.Errorf("no steganography backend found for '%s' image", format)
+}
+
+// DecodeSecretWithKey returns the secret hidden in the image by a backend needing a key (i.e. a PIN),
+// along with the backend that hid it.
+func DecodeSecretWithKey(imgIn io.Reader, key []byte) ([]byte, Steganographer, error) {
+	content, err := io.ReadAll(imgIn)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed reading image")
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed decoding '%s' image", format)
+	}
+
+	var damagedErr error
+
+	for _, s := range backendsForFormat(format) {
+		keyed, ok := s.(KeyedSteganographer)
+		if !ok {
+			continue
+		}
+
+		data, err := keyed.WithKey(key).Extract(bytes.NewReader(content))
+		if err != nil {
+			continue
+		}
+
+		secret, found, err := unframeMarker(s, data)
+		if err != nil {
+			damagedErr = err
+
+			continue
+		}
+
+		if found {
+			return secret, s, nil
+		}
+	}
+
+	if damagedErr != nil {
+		return nil, nil, damagedErr
+	}
+
+	return nil, nil, errors.New("no secret found in the image: wrong PIN?")
+}
+
+// unframeMarker returns the secret following the marker of the backend, correcting the errors of the data.
+// The data embedded by older releases is not protected by the error correcting code.
+func unframeMarker(s Steganographer, data []byte) ([]byte, bool, error) {
+	payload, framed, err := unframe(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !framed {
+		payload = data
 	}
 
-	sizeOfMessage := steganography.GetMessageSizeFromImage(img)
-	msg := steganography.Decode(sizeOfMessage, img)
+	secret, found := trimMarker(s, payload)
 
-	return msg, nil
+	return secret, found, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"image"
 	"image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
 	"testing"
@@ -67,3 +68,48 @@ func TestEncodeDecodeSecret(t *testing.T) {
 
 	require.Equal(t, secret, out)
 }
+
+func TestEncodeSecret_ImageTooSmall(t *testing.T) {
+	testImage := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	// the capacity is net of the marker and of the error correcting code
+	assert.Equal(t, 31, stegoimage.Capacity(testImage))
+
+	var imageBuff bytes.Buffer
+	err := png.Encode(&imageBuff, testImage)
+	require.NoError(t, err)
+
+	err = stegoimage.EncodeSecret(make([]byte, 32), bytes.NewReader(imageBuff.Bytes()), &bytes.Buffer{})
+
+	var capacityErr *stegoimage.CapacityError
+	require.ErrorAs(t, err, &capacityErr)
+	assert.Equal(t, 32, capacityErr.Required)
+	assert.Equal(t, 31, capacityErr.Available)
+
+	var imageOut bytes.Buffer
+	err = stegoimage.EncodeSecret(make([]byte, 31), bytes.NewReader(imageBuff.Bytes()), &imageOut)
+	require.NoError(t, err)
+
+	out, err := stegoimage.DecodeSecret(&imageOut)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, 31), out)
+}
+
+func TestEncodeSecretFromFile_ImageTooSmall(t *testing.T) {
+	inPng := filepath.Join(t.TempDir(), "in.png")
+
+	f, err := os.Create(inPng)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	require.NoError(t, f.Close())
+
+	available, err := stegoimage.CapacityFromFile(stegoimage.Default(), inPng)
+	require.NoError(t, err)
+	assert.Equal(t, 0, available)
+
+	outPng := filepath.Join(t.TempDir(), "out.png")
+
+	err = stegoimage.EncodeSecretFromFile([]byte("test secret"), inPng, outPng)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), inPng)
+	assert.NoFileExists(t, outPng)
+}
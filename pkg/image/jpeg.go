@@ -0,0 +1,858 @@
+package image
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// The DCT backend works on the quantized DCT coefficients of the JPEG images, that are not exposed by
+// the standard library: this file implements the writer of baseline JPEG images from the coefficients
+// (YCbCr without subsampling, standard Huffman tables), and the reader of the quantized coefficients
+// of the luminance of the baseline JPEG images, with their quantization table (progressive images
+// are not supported).
+
+// zigzag maps the zig-zag order of the coefficients to their natural (row-major) order.
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// unscaledQuant are the quantization tables of the luminance and of the chrominance (section K.1 of the spec),
+// in natural order.
+var unscaledQuant = [2][64]int{
+	{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	},
+	{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// huffmanSpec is a Huffman table: the number of codes of every length (1 to 16 bits), and the values.
+type huffmanSpec struct {
+	counts [16]byte
+	values []byte
+}
+
+// standardHuffman are the Huffman tables of section K.3 of the spec: DC and AC of the luminance,
+// DC and AC of the chrominance.
+var standardHuffman = [4]huffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// JPEG markers
+const (
+	markerSOF0 = 0xc0
+	markerSOF1 = 0xc1
+	markerDHT  = 0xc4
+	markerRST0 = 0xd0
+	markerRST7 = 0xd7
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerSOS  = 0xda
+	markerDQT  = 0xdb
+	markerDRI  = 0xdd
+	markerAPP0 = 0xe0
+)
+
+// jpegBlocks are the quantized coefficients of a component, in natural order, block by block (row-major).
+type jpegBlocks struct {
+	blocksPerRow, rows int
+	blocks             [][64]int32
+}
+
+func newJPEGBlocks(blocksPerRow, rows int) *jpegBlocks {
+	return &jpegBlocks{
+		blocksPerRow: blocksPerRow,
+		rows:         rows,
+		blocks:       make([][64]int32, blocksPerRow*rows),
+	}
+}
+
+func (b *jpegBlocks) block(bx, by int) *[64]int32 {
+	return &b.blocks[by*b.blocksPerRow+bx]
+}
+
+// quantTables returns the quantization tables scaled for the quality (1 to 100), as the IJG library does.
+func quantTables(quality int) [2][64]int {
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+
+	tables := [2][64]int{}
+
+	for t := range unscaledQuant {
+		for i, q := range unscaledQuant[t] {
+			tables[t][i] = min(max((q*scale+50)/100, 1), 255)
+		}
+	}
+
+	return tables
+}
+
+// writeJPEG writes the baseline JPEG image of the coefficients of the Y, Cb and Cr components,
+// quantized with the tables of the luminance and of the chrominance.
+func writeJPEG(w io.Writer, width, height int, components [3]*jpegBlocks, quant [2][64]int) error {
+	out := bufio.NewWriter(w)
+
+	_, _ = out.Write([]byte{0xff, markerSOI})
+
+	// JFIF 1.01, no density, no thumbnail
+	writeSegment(out, markerAPP0, []byte{'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0})
+
+	for t, table := range quant {
+		segment := []byte{byte(t)}
+		for _, natural := range zigzag {
+			segment = append(segment, byte(table[natural]))
+		}
+
+		writeSegment(out, markerDQT, segment)
+	}
+
+	sof := []byte{8}
+	sof = binary.BigEndian.AppendUint16(sof, uint16(height))
+	sof = binary.BigEndian.AppendUint16(sof, uint16(width))
+	sof = append(sof, 3, 1, 0x11, 0, 2, 0x11, 1, 3, 0x11, 1)
+	writeSegment(out, markerSOF0, sof)
+
+	for i, spec := range standardHuffman {
+		// the DC tables are class 0, the AC tables class 1
+		segment := append([]byte{byte(i%2<<4 | i/2)}, spec.counts[:]...)
+		writeSegment(out, markerDHT, append(segment, spec.values...))
+	}
+
+	writeSegment(out, markerSOS, []byte{3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 63, 0})
+
+	encoders := [4]huffmanEncoder{}
+	for i, spec := range standardHuffman {
+		encoders[i] = newHuffmanEncoder(spec)
+	}
+
+	bits := &jpegBitWriter{w: out}
+	predictions := [3]int32{}
+
+	for by := 0; by < components[0].rows; by++ {
+		for bx := 0; bx < components[0].blocksPerRow; bx++ {
+			for c, component := range components {
+				table := min(c, 1) * 2
+				predictions[c] = bits.writeBlock(component.block(bx, by), predictions[c], encoders[table], encoders[table+1])
+			}
+		}
+	}
+
+	bits.flush()
+
+	_, _ = out.Write([]byte{0xff, markerEOI})
+
+	return errors.Wrap(out.Flush(), "failed writing jpeg image")
+}
+
+// writeSegment writes the marker segment (the errors are returned by the Flush of the writer).
+func writeSegment(w *bufio.Writer, marker byte, content []byte) {
+	_, _ = w.Write([]byte{0xff, marker})
+	_, _ = w.Write(binary.BigEndian.AppendUint16(nil, uint16(len(content)+2)))
+	_, _ = w.Write(content)
+}
+
+// huffmanEncoder maps the values to their codes and their lengths.
+type huffmanEncoder struct {
+	codes   [256]uint16
+	lengths [256]uint8
+}
+
+func newHuffmanEncoder(spec huffmanSpec) huffmanEncoder {
+	e := huffmanEncoder{}
+	code, k := uint16(0), 0
+
+	for length, count := range spec.counts {
+		for i := 0; i < int(count); i++ {
+			e.codes[spec.values[k]] = code
+			e.lengths[spec.values[k]] = uint8(length + 1)
+			code++
+			k++
+		}
+
+		code <<= 1
+	}
+
+	return e
+}
+
+// jpegBitWriter writes the entropy coded data, stuffing a zero byte after every 0xff byte.
+type jpegBitWriter struct {
+	w    *bufio.Writer
+	bits uint32
+	n    uint
+}
+
+func (b *jpegBitWriter) write(bits uint32, n uint) {
+	b.bits = b.bits<<n | bits&(1<<n-1)
+	b.n += n
+
+	for b.n >= 8 {
+		c := byte(b.bits >> (b.n - 8))
+		_ = b.w.WriteByte(c)
+
+		if c == 0xff {
+			_ = b.w.WriteByte(0)
+		}
+
+		b.n -= 8
+	}
+}
+
+// flush pads the last byte with ones.
+func (b *jpegBitWriter) flush() {
+	if b.n > 0 {
+		b.write(0xff, 8-b.n)
+	}
+}
+
+func (b *jpegBitWriter) writeHuffman(e huffmanEncoder, value byte) {
+	b.write(uint32(e.codes[value]), uint(e.lengths[value]))
+}
+
+// writeValue writes the category of the value with the Huffman code of the symbol, followed by its bits.
+func (b *jpegBitWriter) writeValue(e huffmanEncoder, run byte, value int32) {
+	magnitude := value
+	if value < 0 {
+		magnitude = -value
+		// the negative values are written as their one's complement
+		value--
+	}
+
+	category := uint(0)
+	for magnitude > 0 {
+		category++
+		magnitude >>= 1
+	}
+
+	b.writeHuffman(e, run<<4|byte(category))
+	b.write(uint32(value), category)
+}
+
+// writeBlock writes the coefficients of the block, returning its DC coefficient (the prediction of the next one).
+func (b *jpegBitWriter) writeBlock(block *[64]int32, prediction int32, dc, ac huffmanEncoder) int32 {
+	b.writeValue(dc, 0, block[0]-prediction)
+
+	run := byte(0)
+
+	for k := 1; k < 64; k++ {
+		value := block[zigzag[k]]
+		if value == 0 {
+			run++
+
+			continue
+		}
+
+		for run > 15 {
+			// ZRL, sixteen zeros
+			b.writeHuffman(ac, 0xf0)
+			run -= 16
+		}
+
+		b.writeValue(ac, run, value)
+		run = 0
+	}
+
+	if run > 0 {
+		// EOB
+		b.writeHuffman(ac, 0x00)
+	}
+
+	return block[0]
+}
+
+// jpegComponent is a component of the frame of the image being read.
+type jpegComponent struct {
+	id     byte
+	h, v   int
+	quant  byte
+	blocks *jpegBlocks
+}
+
+// jpegReader reads the quantized coefficients of a baseline JPEG image.
+type jpegReader struct {
+	data          []byte
+	pos           int
+	width, height int
+	components    []*jpegComponent
+	hMax, vMax    int
+	restart       int
+	quant         [4]*[64]int
+	huffman       [2][4]*huffmanDecoder
+}
+
+// readJPEGLuma returns the quantized coefficients of the luminance (the first component) of the JPEG image,
+// along with the size of the image and the quantization table of the luminance (in natural order).
+func readJPEGLuma(data []byte) (*jpegBlocks, int, int, [64]int, error) {
+	r := &jpegReader{data: data}
+
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, 0, 0, [64]int{}, errors.New("not a jpeg image")
+	}
+
+	r.pos = 2
+
+	for {
+		marker, segment, err := r.nextSegment()
+		if err != nil {
+			return nil, 0, 0, [64]int{}, err
+		}
+
+		switch {
+		case marker == markerEOI:
+			if r.components == nil {
+				return nil, 0, 0, [64]int{}, errors.New("missing jpeg frame")
+			}
+
+			luma := r.components[0]
+			if r.quant[luma.quant] == nil {
+				return nil, 0, 0, [64]int{}, errors.New("missing jpeg quantization table")
+			}
+
+			return luma.blocks, r.width, r.height, *r.quant[luma.quant], nil
+		case marker == markerSOF0 || marker == markerSOF1:
+			err = r.readFrame(segment)
+		case marker >= 0xc2 && marker <= 0xcf && marker != markerDHT && marker != 0xc8 && marker != 0xcc:
+			err = errors.Errorf("unsupported jpeg image (SOF%d): only the baseline images are supported", marker-markerSOF0)
+		case marker == markerDHT:
+			err = r.readHuffman(segment)
+		case marker == markerDQT:
+			err = r.readQuant(segment)
+		case marker == markerDRI:
+			if len(segment) < 2 {
+				return nil, 0, 0, [64]int{}, errors.New("invalid jpeg restart interval")
+			}
+
+			r.restart = int(binary.BigEndian.Uint16(segment))
+		case marker == markerSOS:
+			err = r.readScan(segment)
+		}
+
+		if err != nil {
+			return nil, 0, 0, [64]int{}, err
+		}
+	}
+}
+
+// nextSegment returns the next marker and the content of its segment (empty for the markers without a segment).
+func (r *jpegReader) nextSegment() (byte, []byte, error) {
+	// the fill bytes before the marker are skipped
+	for r.pos < len(r.data) && r.data[r.pos] != 0xff {
+		r.pos++
+	}
+
+	for r.pos < len(r.data) && r.data[r.pos] == 0xff {
+		r.pos++
+	}
+
+	if r.pos >= len(r.data) {
+		return 0, nil, errors.New("unexpected end of jpeg image")
+	}
+
+	marker := r.data[r.pos]
+	r.pos++
+
+	if marker == markerEOI || marker == markerSOI || (marker >= markerRST0 && marker <= markerRST7) {
+		return marker, nil, nil
+	}
+
+	if r.pos+2 > len(r.data) {
+		return 0, nil, errors.New("unexpected end of jpeg image")
+	}
+
+	length := int(binary.BigEndian.Uint16(r.data[r.pos:]))
+	if length < 2 || r.pos+length > len(r.data) {
+		return 0, nil, errors.New("invalid jpeg segment length")
+	}
+
+	segment := r.data[r.pos+2 : r.pos+length]
+	r.pos += length
+
+	return marker, segment, nil
+}
+
+func (r *jpegReader) readFrame(segment []byte) error {
+	if len(segment) < 6 || segment[0] != 8 {
+		return errors.New("unsupported jpeg frame: only 8 bits precision is supported")
+	}
+
+	r.height = int(binary.BigEndian.Uint16(segment[1:]))
+	r.width = int(binary.BigEndian.Uint16(segment[3:]))
+	count := int(segment[5])
+
+	if r.width == 0 || r.height == 0 || count == 0 || len(segment) < 6+count*3 {
+		return errors.New("invalid jpeg frame")
+	}
+
+	r.components = make([]*jpegComponent, count)
+	r.hMax, r.vMax = 1, 1
+
+	for i := range r.components {
+		c := segment[6+i*3:]
+		component := &jpegComponent{id: c[0], h: int(c[1] >> 4), v: int(c[1] & 0x0f), quant: c[2]}
+
+		if component.h < 1 || component.h > 4 || component.v < 1 || component.v > 4 {
+			return errors.New("invalid jpeg sampling factors")
+		}
+
+		if component.quant > 3 {
+			return errors.New("invalid jpeg quantization table")
+		}
+
+		r.hMax, r.vMax = max(r.hMax, component.h), max(r.vMax, component.v)
+		r.components[i] = component
+	}
+
+	mcusX, mcusY := r.mcus()
+
+	for _, component := range r.components {
+		component.blocks = newJPEGBlocks(mcusX*component.h, mcusY*component.v)
+	}
+
+	return nil
+}
+
+// mcus returns the number of MCUs (minimum coded units) of the interleaved scans, by row and by column.
+func (r *jpegReader) mcus() (int, int) {
+	return (r.width + 8*r.hMax - 1) / (8 * r.hMax), (r.height + 8*r.vMax - 1) / (8 * r.vMax)
+}
+
+func (r *jpegReader) readQuant(segment []byte) error {
+	for len(segment) > 0 {
+		precision, id := segment[0]>>4, segment[0]&0x0f
+		if precision > 1 || id > 3 {
+			return errors.New("invalid jpeg quantization table")
+		}
+
+		size := 64 << precision
+		if len(segment) < 1+size {
+			return errors.New("invalid jpeg quantization table")
+		}
+
+		table := [64]int{}
+
+		for k, natural := range zigzag {
+			if precision == 0 {
+				table[natural] = int(segment[1+k])
+			} else {
+				table[natural] = int(binary.BigEndian.Uint16(segment[1+k*2:]))
+			}
+		}
+
+		r.quant[id] = &table
+		segment = segment[1+size:]
+	}
+
+	return nil
+}
+
+func (r *jpegReader) readHuffman(segment []byte) error {
+	for len(segment) > 0 {
+		if len(segment) < 17 {
+			return errors.New("invalid jpeg huffman table")
+		}
+
+		class, id := segment[0]>>4, segment[0]&0x0f
+		if class > 1 || id > 3 {
+			return errors.New("invalid jpeg huffman table")
+		}
+
+		spec := huffmanSpec{}
+		copy(spec.counts[:], segment[1:17])
+
+		total := 0
+		for _, count := range spec.counts {
+			total += int(count)
+		}
+
+		if total > 256 || len(segment) < 17+total {
+			return errors.New("invalid jpeg huffman table")
+		}
+
+		spec.values = segment[17 : 17+total]
+		r.huffman[class][id] = newHuffmanDecoder(spec)
+		segment = segment[17+total:]
+	}
+
+	return nil
+}
+
+// scanComponent is a component of a scan, with its Huffman tables.
+type scanComponent struct {
+	*jpegComponent
+	dc, ac     *huffmanDecoder
+	prediction int32
+}
+
+func (r *jpegReader) readScan(segment []byte) error {
+	if r.components == nil {
+		return errors.New("jpeg scan before the frame")
+	}
+
+	if len(segment) < 1 || len(segment) < 1+int(segment[0])*2+3 {
+		return errors.New("invalid jpeg scan")
+	}
+
+	scan := make([]*scanComponent, segment[0])
+
+	for i := range scan {
+		id, tables := segment[1+i*2], segment[2+i*2]
+
+		for _, component := range r.components {
+			if component.id == id {
+				scan[i] = &scanComponent{
+					jpegComponent: component,
+					dc:            r.huffman[0][tables>>4&3],
+					ac:            r.huffman[1][tables&3],
+				}
+			}
+		}
+
+		if scan[i] == nil || scan[i].dc == nil || scan[i].ac == nil {
+			return errors.New("invalid jpeg scan component")
+		}
+	}
+
+	bits := &jpegBitReader{data: r.data, pos: r.pos}
+
+	units := r.scanUnits(scan)
+	for mcu, blocks := range units {
+		if r.restart > 0 && mcu > 0 && mcu%r.restart == 0 {
+			// the data is aligned to a byte before the RST marker, and the predictions are reset
+			if err := bits.restart(); err != nil {
+				return err
+			}
+
+			for _, component := range scan {
+				component.prediction = 0
+			}
+		}
+
+		for _, block := range blocks {
+			if err := bits.readBlock(block.component, block.block); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.pos = bits.pos
+
+	return nil
+}
+
+// scanBlock is a block of a component of the scan.
+type scanBlock struct {
+	component *scanComponent
+	block     *[64]int32
+}
+
+// scanUnits returns the blocks of the MCUs of the scan, in order.
+func (r *jpegReader) scanUnits(scan []*scanComponent) [][]scanBlock {
+	units := [][]scanBlock{}
+
+	// a scan with a single component is not interleaved: every block is an MCU
+	if len(scan) == 1 {
+		c := scan[0]
+		blocksX := ((r.width*c.h+r.hMax-1)/r.hMax + 7) / 8
+		blocksY := ((r.height*c.v+r.vMax-1)/r.vMax + 7) / 8
+
+		for by := 0; by < blocksY; by++ {
+			for bx := 0; bx < blocksX; bx++ {
+				units = append(units, []scanBlock{{c, c.blocks.block(bx, by)}})
+			}
+		}
+
+		return units
+	}
+
+	mcusX, mcusY := r.mcus()
+
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			unit := []scanBlock{}
+
+			for _, c := range scan {
+				for v := 0; v < c.v; v++ {
+					for h := 0; h < c.h; h++ {
+						unit = append(unit, scanBlock{c, c.blocks.block(mx*c.h+h, my*c.v+v)})
+					}
+				}
+			}
+
+			units = append(units, unit)
+		}
+	}
+
+	return units
+}
+
+// huffmanDecoder decodes the canonical Huffman codes, bit by bit.
+type huffmanDecoder struct {
+	maxCode [17]int32
+	minCode [17]int32
+	offset  [17]int32
+	values  []byte
+}
+
+func newHuffmanDecoder(spec huffmanSpec) *huffmanDecoder {
+	d := &huffmanDecoder{values: spec.values}
+	code, k := int32(0), int32(0)
+
+	for length := 1; length <= 16; length++ {
+		count := int32(spec.counts[length-1])
+
+		d.offset[length] = k
+		d.minCode[length] = code
+		d.maxCode[length] = code + count - 1
+
+		code += count
+		k += count
+		code <<= 1
+	}
+
+	return d
+}
+
+// jpegBitReader reads the entropy coded data, removing the zero bytes stuffed after the 0xff bytes.
+type jpegBitReader struct {
+	data []byte
+	pos  int
+	bits uint32
+	n    uint
+}
+
+var errJPEGData = errors.New("invalid jpeg entropy coded data")
+
+func (b *jpegBitReader) bit() (int32, error) {
+	if b.n == 0 {
+		if b.pos >= len(b.data) {
+			return 0, errJPEGData
+		}
+
+		c := b.data[b.pos]
+		if c == 0xff {
+			if b.pos+1 >= len(b.data) || b.data[b.pos+1] != 0 {
+				// a marker in the middle of the data
+				return 0, errJPEGData
+			}
+
+			b.pos++
+		}
+
+		b.pos++
+		b.bits, b.n = uint32(c), 8
+	}
+
+	b.n--
+
+	return int32(b.bits >> b.n & 1), nil
+}
+
+func (b *jpegBitReader) read(n int) (int32, error) {
+	value := int32(0)
+
+	for i := 0; i < n; i++ {
+		bit, err := b.bit()
+		if err != nil {
+			return 0, err
+		}
+
+		value = value<<1 | bit
+	}
+
+	return value, nil
+}
+
+// restart skips the rest of the byte and the RST marker.
+func (b *jpegBitReader) restart() error {
+	b.n = 0
+
+	if b.pos+1 >= len(b.data) || b.data[b.pos] != 0xff || b.data[b.pos+1] < markerRST0 || b.data[b.pos+1] > markerRST7 {
+		return errors.New("missing jpeg restart marker")
+	}
+
+	b.pos += 2
+
+	return nil
+}
+
+func (b *jpegBitReader) decode(d *huffmanDecoder) (byte, error) {
+	code := int32(0)
+
+	for length := 1; length <= 16; length++ {
+		bit, err := b.bit()
+		if err != nil {
+			return 0, err
+		}
+
+		code = code<<1 | bit
+
+		if code <= d.maxCode[length] {
+			index := d.offset[length] + code - d.minCode[length]
+			if int(index) >= len(d.values) {
+				break
+			}
+
+			return d.values[index], nil
+		}
+	}
+
+	return 0, errors.Wrap(errJPEGData, "invalid huffman code")
+}
+
+// value reads a value of the category, written as its one's complement if negative.
+func (b *jpegBitReader) value(category byte) (int32, error) {
+	if category == 0 {
+		return 0, nil
+	}
+
+	if category > 16 {
+		return 0, errJPEGData
+	}
+
+	value, err := b.read(int(category))
+	if err != nil {
+		return 0, err
+	}
+
+	if value < 1<<(category-1) {
+		value += -1<<category + 1
+	}
+
+	return value, nil
+}
+
+func (b *jpegBitReader) readBlock(c *scanComponent, block *[64]int32) error {
+	category, err := b.decode(c.dc)
+	if err != nil {
+		return err
+	}
+
+	diff, err := b.value(category)
+	if err != nil {
+		return err
+	}
+
+	c.prediction += diff
+	block[0] = c.prediction
+
+	for k := 1; k < 64; k++ {
+		symbol, err := b.decode(c.ac)
+		if err != nil {
+			return err
+		}
+
+		run, size := int(symbol>>4), symbol&0x0f
+
+		if size == 0 {
+			if run != 15 {
+				// EOB
+				break
+			}
+
+			// ZRL, sixteen zeros (the loop adds the last one)
+			k += 15
+
+			continue
+		}
+
+		k += run
+		if k > 63 {
+			return errors.Wrap(errJPEGData, "too many coefficients")
+		}
+
+		value, err := b.value(size)
+		if err != nil {
+			return err
+		}
+
+		block[zigzag[k]] = value
+	}
+
+	return nil
+}
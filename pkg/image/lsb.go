@@ -0,0 +1,210 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/auyer/steganography"
+	"github.com/pkg/errors"
+)
+
+// sizeHeaderLen is the number of bytes used to store the size of the secret in the image.
+const sizeHeaderLen = 4
+
+// The LSB backend hides the data row by row in a rectangle inside the image, leaving a margin around it,
+// after a header with a synchronization word: the header is searched in the top left corner of the image,
+// so the data is found also if the edges of the image were cropped (up to the margin).
+const (
+	// lsbSync marks the start of the data
+	lsbSync = "\x5b\xe6\x0d\x93"
+	// lsbHeaderLen is the size of the header: the synchronization word, the length of the rows and the size of the data
+	lsbHeaderLen = len(lsbSync) + 2 + sizeHeaderLen
+	// lsbMarginRatio is the inverse of the ratio of the shortest side of the image left as margin on every edge
+	lsbMarginRatio = 20
+)
+
+func init() {
+	Register(&LSB{})
+}
+
+// LSB hides the data in the least significant bits of the RGB channels of the pixels.
+// The output images are PNG, because any lossy compression would destroy the data.
+type LSB struct{}
+
+func (*LSB) Name() string {
+	return "lsb"
+}
+
+func (*LSB) ID() byte {
+	return 1
+}
+
+func (*LSB) SupportedFormats() []string {
+	return []string{"png"}
+}
+
+// Capacity returns the available bytes: every pixel inside the margin hides 3 bits (one for each RGB channel).
+func (*LSB) Capacity(width, height int) int {
+	layout := newLSBLayout(width, height)
+
+	available := layout.rowLen*(height-2*layout.margin)*3/8 - lsbHeaderLen
+	if available < 0 {
+		return 0
+	}
+
+	return available
+}
+
+func (l *LSB) Embed(data []byte, cover image.Image, w io.Writer) error {
+	bounds := cover.Bounds()
+	if available := l.Capacity(bounds.Dx(), bounds.Dy()); len(data) > available {
+		return &CapacityError{Required: len(data), Available: available}
+	}
+
+	img := newNRGBA(cover)
+	layout := newLSBLayout(bounds.Dx(), bounds.Dy())
+
+	payload := make([]byte, 0, lsbHeaderLen+len(data))
+	payload = append(payload, lsbSync...)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(layout.rowLen))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(data)))
+	payload = append(payload, data...)
+
+	layout.write(img, payload)
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return errors.Wrap(err, "failed encoding png image")
+	}
+
+	_, err := buf.WriteTo(w)
+
+	return errors.Wrap(err, "failed writing out image")
+}
+
+func (l *LSB) Extract(r io.Reader) ([]byte, error) {
+	decoded, format, err := image.Decode(bufio.NewReader(r))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed decoding '%s' image", format)
+	}
+
+	if data, found := extractSynced(newNRGBA(decoded)); found {
+		return data, nil
+	}
+
+	// the images created by older releases have the data in the layout of the steganography library,
+	// that stores its size in the first bytes, and reserves the same amount again when checking it
+	bounds := decoded.Bounds()
+
+	size := steganography.GetMessageSizeFromImage(decoded)
+	if available := bounds.Dx()*bounds.Dy()*3/8 - 2*sizeHeaderLen; int64(size) > int64(available) {
+		return nil, errors.Errorf("invalid size of the hidden data: %d bytes, %d available", size, available)
+	}
+
+	return steganography.Decode(size, decoded), nil
+}
+
+// extractSynced searches the header in the top left corner of the image (as large as the margins of an image
+// cropped up to its margins), returning the data following it.
+func extractSynced(img *image.NRGBA) ([]byte, bool) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	search := min(width, height)*2/lsbMarginRatio + 1
+
+	for y := 0; y < search; y++ {
+		for x := 0; x < search; x++ {
+			// the header is in the first row, or the rows are as wide as the image
+			header := lsbLayout{x: x, y: y, rowLen: width}.read(img, lsbHeaderLen)
+			if !bytes.HasPrefix(header, []byte(lsbSync)) {
+				continue
+			}
+
+			rowLen := int(binary.BigEndian.Uint16(header[len(lsbSync):]))
+			size := int64(binary.BigEndian.Uint32(header[len(lsbSync)+2:]))
+
+			// a damaged header, or random bits matching the synchronization word
+			if rowLen == 0 || size > int64(rowLen)*int64(height)*3/8 {
+				continue
+			}
+
+			data := lsbLayout{x: x, y: y, rowLen: rowLen}.read(img, lsbHeaderLen+int(size))
+
+			return data[lsbHeaderLen:], true
+		}
+	}
+
+	return nil, false
+}
+
+// lsbLayout is the rectangle where the bits are hidden: from the (x, y) pixel, in rows of rowLen pixels.
+type lsbLayout struct {
+	x, y   int
+	rowLen int
+	margin int
+}
+
+func newLSBLayout(width, height int) lsbLayout {
+	margin := min(width, height) / lsbMarginRatio
+
+	// the header has to be in the first row, to be found: the small images have no margin
+	// (and the header can span more rows, as long as they are as wide as the image)
+	if (width-2*margin)*3 < lsbHeaderLen*8 {
+		margin = 0
+	}
+
+	rowLen := min(width-2*margin, 0xffff)
+
+	return lsbLayout{x: margin, y: margin, rowLen: rowLen, margin: margin}
+}
+
+// offset returns the offset in the pixels of the image of the channel hiding the i-th bit,
+// or false if it is outside the image (i.e. cropped).
+func (l lsbLayout) offset(img *image.NRGBA, i int) (int, bool) {
+	pixel := i / 3
+	x, y := l.x+pixel%l.rowLen, l.y+pixel/l.rowLen
+
+	if x >= img.Rect.Dx() || y >= img.Rect.Dy() {
+		return 0, false
+	}
+
+	return y*img.Stride + x*4 + i%3, true
+}
+
+func (l lsbLayout) write(img *image.NRGBA, data []byte) {
+	for i := 0; i < len(data)*8; i++ {
+		if pos, ok := l.offset(img, i); ok {
+			img.Pix[pos] = img.Pix[pos]&^1 | data[i/8]>>(7-i%8)&1
+		}
+	}
+}
+
+// read reads the bytes, with the bits outside the image set to zero.
+func (l lsbLayout) read(img *image.NRGBA, n int) []byte {
+	out := make([]byte, n)
+
+	for i := 0; i < n*8; i++ {
+		if pos, ok := l.offset(img, i); ok {
+			out[i/8] |= img.Pix[pos] & 1 << (7 - i%8)
+		}
+	}
+
+	return out
+}
+
+// newNRGBA returns a copy of the image as NRGBA, with the origin in (0, 0).
+func newNRGBA(src image.Image) *image.NRGBA {
+	bounds := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			img.Set(x, y, color.NRGBAModel.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+
+	return img
+}
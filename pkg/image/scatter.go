@@ -0,0 +1,201 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	mathrand "math/rand/v2"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrKeyRequired is returned when a keyed backend is used without a key.
+var ErrKeyRequired = errors.New("the steganography backend needs a key (PIN)")
+
+// scatterSaltLen is the size of the random salt of every image, hidden in the clear in the LSB of its first
+// channels: the seed is derived from the PIN and the salt with Argon2id, so that the seeds of all the PINs
+// cannot be precomputed once for all the images. A short PIN can still be found trying all of them
+// on a single image (i.e. 10^4 Argon2id derivations for 4 digits), so the PINs should not be too short.
+const scatterSaltLen = 16
+
+// KeyedSteganographer is a backend needing a secret key (i.e. a PIN of the holder) to embed and extract the data.
+type KeyedSteganographer interface {
+	Steganographer
+	// WithKey returns the backend using the key.
+	WithKey(key []byte) Steganographer
+}
+
+func init() {
+	Register(&ScatteredLSB{})
+}
+
+// ScatteredLSB hides the data in the least significant bits of the RGB channels, like LSB,
+// but the channels are chosen by a PRNG seeded with the key: the data cannot be found without the key,
+// and it is spread across the whole image, making the statistical detection harder.
+type ScatteredLSB struct {
+	key []byte
+}
+
+func (*ScatteredLSB) Name() string {
+	return "lsb-scatter"
+}
+
+func (*ScatteredLSB) ID() byte {
+	return 3
+}
+
+func (*ScatteredLSB) SupportedFormats() []string {
+	return []string{"png"}
+}
+
+func (*ScatteredLSB) Capacity(width, height int) int {
+	available := width*height*3/8 - sizeHeaderLen - scatterSaltLen
+	if available < 0 {
+		return 0
+	}
+
+	return available
+}
+
+func (*ScatteredLSB) WithKey(key []byte) Steganographer {
+	return &ScatteredLSB{key: key}
+}
+
+// seed derives the seed of the PRNG from the key and the salt of the image.
+func (s *ScatteredLSB) seed(salt []byte) [32]byte {
+	seed := [32]byte{}
+	copy(seed[:], argon2.IDKey(s.key, salt, 1, 64*1024, 4, 32))
+
+	return seed
+}
+
+func (s *ScatteredLSB) Embed(data []byte, cover image.Image, w io.Writer) error {
+	if s.key == nil {
+		return ErrKeyRequired
+	}
+
+	bounds := cover.Bounds()
+	if available := s.Capacity(bounds.Dx(), bounds.Dy()); len(data) > available {
+		return &CapacityError{Required: len(data), Available: available}
+	}
+
+	img := newNRGBA(cover)
+
+	payload := make([]byte, sizeHeaderLen, sizeHeaderLen+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	payload = append(payload, data...)
+
+	salt := make([]byte, scatterSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "failed generating random salt")
+	}
+
+	// the salt is hidden in the first channels, that are skipped by the PRNG
+	for i := 0; i < scatterSaltLen*8; i++ {
+		pos := channelOffset(i)
+		img.Pix[pos] = img.Pix[pos]&^1 | salt[i/8]>>(7-i%8)&1
+	}
+
+	positions := s.newPositions(img, salt)
+
+	for i := 0; i < len(payload)*8; i++ {
+		bit := payload[i/8] >> (7 - i%8) & 1
+		pos := positions.next()
+		img.Pix[pos] = img.Pix[pos]&^1 | bit
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return errors.Wrap(err, "failed encoding png image")
+	}
+
+	_, err := buf.WriteTo(w)
+
+	return errors.Wrap(err, "failed writing out image")
+}
+
+func (s *ScatteredLSB) Extract(r io.Reader) ([]byte, error) {
+	if s.key == nil {
+		return nil, ErrKeyRequired
+	}
+
+	decoded, format, err := image.Decode(bufio.NewReader(r))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed decoding '%s' image", format)
+	}
+
+	bounds := decoded.Bounds()
+
+	img, ok := decoded.(*image.NRGBA)
+	if !ok || img.Rect.Min != (image.Point{}) {
+		img = newNRGBA(decoded)
+	}
+
+	// the salt and the size have to fit
+	if s.Capacity(bounds.Dx(), bounds.Dy()) == 0 {
+		return nil, errors.New("image too small to hide data")
+	}
+
+	salt := make([]byte, scatterSaltLen)
+	for i := 0; i < scatterSaltLen*8; i++ {
+		salt[i/8] |= img.Pix[channelOffset(i)] & 1 << (7 - i%8)
+	}
+
+	positions := s.newPositions(img, salt)
+
+	readBytes := func(n int) []byte {
+		out := make([]byte, n)
+		for i := 0; i < n*8; i++ {
+			out[i/8] |= img.Pix[positions.next()] & 1 << (7 - i%8)
+		}
+
+		return out
+	}
+
+	size := binary.BigEndian.Uint32(readBytes(sizeHeaderLen))
+	if available := s.Capacity(bounds.Dx(), bounds.Dy()); int64(size) > int64(available) {
+		return nil, errors.Errorf("invalid size of the hidden data: %d bytes, %d available (wrong PIN?)", size, available)
+	}
+
+	return readBytes(int(size)), nil
+}
+
+// positions returns the pseudo random RGB channels (as offsets in the pixels of the image) where the bits are hidden.
+// The first channels, holding the salt, are skipped.
+type positions struct {
+	rnd      *mathrand.Rand
+	channels int
+	used     map[int]bool
+}
+
+func (s *ScatteredLSB) newPositions(img *image.NRGBA, salt []byte) *positions {
+	return &positions{
+		rnd:      mathrand.New(mathrand.NewChaCha8(s.seed(salt))),
+		channels: img.Rect.Dx()*img.Rect.Dy()*3 - scatterSaltLen*8,
+		used:     map[int]bool{},
+	}
+}
+
+// next returns the offset of the next unused channel. The capacity is checked by the caller.
+func (p *positions) next() int {
+	for {
+		channel := p.rnd.IntN(p.channels)
+		if p.used[channel] {
+			continue
+		}
+
+		p.used[channel] = true
+
+		return channelOffset(scatterSaltLen*8 + channel)
+	}
+}
+
+// channelOffset returns the offset in the pixels of the RGB channel, skipping the alpha channels.
+func channelOffset(channel int) int {
+	return channel/3*4 + channel%3
+}
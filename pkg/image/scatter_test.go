@@ -0,0 +1,64 @@
+package image_test
+
+import (
+	"bytes"
+	"testing"
+
+	stegoimage "github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScatteredLSB(t *testing.T) {
+	backend, err := stegoimage.Backend("lsb-scatter")
+	require.NoError(t, err)
+
+	keyed, ok := backend.(stegoimage.KeyedSteganographer)
+	require.True(t, ok)
+
+	// a key is needed
+	err = stegoimage.EncodeSecretWith(backend, []byte("test secret"), newPNG(t, 64, 64), &bytes.Buffer{})
+	require.ErrorIs(t, err, stegoimage.ErrKeyRequired)
+
+	secret := []byte("test secret")
+
+	var imageOut bytes.Buffer
+	err = stegoimage.EncodeSecretWith(keyed.WithKey([]byte("1234")), secret, newPNG(t, 64, 64), &imageOut)
+	require.NoError(t, err)
+
+	out, detected, err := stegoimage.DecodeSecretWithKey(bytes.NewReader(imageOut.Bytes()), []byte("1234"))
+	require.NoError(t, err)
+	assert.Equal(t, secret, out)
+	assert.Equal(t, "lsb-scatter", detected.Name())
+
+	_, _, err = stegoimage.DecodeSecretWithKey(bytes.NewReader(imageOut.Bytes()), []byte("4321"))
+	require.Error(t, err)
+
+	// without the key the secret is not found
+	out, err = stegoimage.DecodeSecret(bytes.NewReader(imageOut.Bytes()))
+	if err == nil {
+		assert.NotContains(t, string(out), string(secret))
+	}
+}
+
+func TestScatteredLSB_Salt(t *testing.T) {
+	backend, err := stegoimage.Backend("lsb-scatter")
+	require.NoError(t, err)
+
+	keyed := backend.(stegoimage.KeyedSteganographer).WithKey([]byte("1234"))
+
+	// every image has its own salt: the same PIN hides the data in different channels
+	var first, second bytes.Buffer
+	require.NoError(t, stegoimage.EncodeSecretWith(keyed, []byte("test secret"), newPNG(t, 64, 64), &first))
+	require.NoError(t, stegoimage.EncodeSecretWith(keyed, []byte("test secret"), newPNG(t, 64, 64), &second))
+	assert.NotEqual(t, first.Bytes(), second.Bytes())
+
+	for _, img := range [][]byte{first.Bytes(), second.Bytes()} {
+		out, _, err := stegoimage.DecodeSecretWithKey(bytes.NewReader(img), []byte("1234"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("test secret"), out)
+	}
+
+	_, err = keyed.Extract(newPNG(t, 4, 4))
+	require.Error(t, err)
+}
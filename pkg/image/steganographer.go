@@ -0,0 +1,146 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Steganographer hides secrets inside images.
+type Steganographer interface {
+	// Name is the unique name of the backend, used to select it.
+	Name() string
+	// ID identifies the backend in the marker embedded along with the secret.
+	ID() byte
+	// SupportedFormats returns the image formats (as named by the image package) written by Embed
+	// and read by Extract. The first one is the format of the images written by Embed.
+	SupportedFormats() []string
+	// Capacity returns the number of bytes that can be embedded in an image of the given size.
+	Capacity(width, height int) int
+	// Embed hides the data into the cover image, writing the resulting image.
+	Embed(data []byte, cover image.Image, w io.Writer) error
+	// Extract returns the data hidden in the image.
+	Extract(r io.Reader) ([]byte, error)
+}
+
+// DefaultBackend is the name of the default steganography backend.
+const DefaultBackend = "lsb"
+
+// the marker embedded before the secret identifies the backend that hid it
+const (
+	markerMagic = "stg"
+	markerLen   = len(markerMagic) + 1
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Steganographer{}
+)
+
+// Register makes a steganography backend available by its name.
+// It panics if a backend with the same name or ID is already registered.
+func Register(s Steganographer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, registered := range registry {
+		if registered.Name() == s.Name() || registered.ID() == s.ID() {
+			panic("image: Register called twice for steganographer " + s.Name())
+		}
+	}
+
+	registry[s.Name()] = s
+}
+
+// Backend returns the registered backend with the given name.
+func Backend(name string) (Steganographer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	s, found := registry[name]
+	if !found {
+		return nil, errors.Errorf("unknown steganography backend '%s' (available: %v)", name, backendNames())
+	}
+
+	return s, nil
+}
+
+// Backends returns the names of the registered backends.
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return backendNames()
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Default returns the default backend.
+func Default() Steganographer {
+	s, err := Backend(DefaultBackend)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Extension returns the file extension of the images written by the backend.
+func Extension(s Steganographer) string {
+	format := s.SupportedFormats()[0]
+	if format == "jpeg" {
+		return ".jpg"
+	}
+
+	return "." + format
+}
+
+// backendsForFormat returns the backends able to read images of the given format.
+func backendsForFormat(format string) []Steganographer {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	backends := []Steganographer{}
+
+	for _, name := range backendNames() {
+		for _, f := range registry[name].SupportedFormats() {
+			if f == format {
+				backends = append(backends, registry[name])
+
+				break
+			}
+		}
+	}
+
+	return backends
+}
+
+func withMarker(s Steganographer, secret []byte) []byte {
+	data := make([]byte, 0, markerLen+len(secret))
+	data = append(data, markerMagic...)
+	data = append(data, s.ID())
+
+	return append(data, secret...)
+}
+
+// trimMarker returns the secret following the marker of the backend, if found.
+func trimMarker(s Steganographer, data []byte) ([]byte, bool) {
+	if len(data) < markerLen || !bytes.HasPrefix(data, []byte(markerMagic)) || data[markerLen-1] != s.ID() {
+		return nil, false
+	}
+
+	return data[markerLen:], true
+}
@@ -0,0 +1,102 @@
+package image_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/auyer/steganography"
+	stegoimage "github.com/enrichman/stegosecrets/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawPNG is a fake backend storing the data in the clear in the pixels of a grayscale PNG.
+type rawPNG struct{}
+
+func (rawPNG) Name() string               { return "raw-test" }
+func (rawPNG) ID() byte                   { return 250 }
+func (rawPNG) SupportedFormats() []string { return []string{"png"} }
+
+func (rawPNG) Capacity(width, height int) int { return width*height - 1 }
+
+func (rawPNG) Embed(data []byte, cover image.Image, w io.Writer) error {
+	img := image.NewGray(cover.Bounds())
+	img.Pix[0] = byte(len(data))
+	copy(img.Pix[1:], data)
+
+	return png.Encode(w, img)
+}
+
+func (rawPNG) Extract(r io.Reader) ([]byte, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		return []byte{}, nil
+	}
+
+	return gray.Pix[1 : 1+int(gray.Pix[0])], nil
+}
+
+func init() {
+	stegoimage.Register(rawPNG{})
+}
+
+func TestBackend(t *testing.T) {
+	assert.Contains(t, stegoimage.Backends(), stegoimage.DefaultBackend)
+	assert.Equal(t, stegoimage.DefaultBackend, stegoimage.Default().Name())
+	assert.Equal(t, ".png", stegoimage.Extension(stegoimage.Default()))
+
+	_, err := stegoimage.Backend("unknown")
+	require.Error(t, err)
+
+	assert.Panics(t, func() { stegoimage.Register(rawPNG{}) })
+}
+
+func TestDecodeSecret_DetectBackend(t *testing.T) {
+	backend, err := stegoimage.Backend("raw-test")
+	require.NoError(t, err)
+
+	for _, s := range []stegoimage.Steganographer{stegoimage.Default(), backend} {
+		secret := []byte("test secret")
+
+		var imageOut bytes.Buffer
+		err = stegoimage.EncodeSecretWith(s, secret, newPNG(t, 64, 64), &imageOut)
+		require.NoError(t, err)
+
+		out, detected, err := stegoimage.DecodeSecretBackend(&imageOut)
+		require.NoError(t, err)
+		assert.Equal(t, secret, out)
+		assert.Equal(t, s.Name(), detected.Name())
+	}
+}
+
+func TestDecodeSecret_Legacy(t *testing.T) {
+	// images created by older releases have no marker
+	img, err := png.Decode(newPNG(t, 64, 64))
+	require.NoError(t, err)
+
+	var imageOut bytes.Buffer
+	err = steganography.Encode(&imageOut, img, []byte("legacy secret"))
+	require.NoError(t, err)
+
+	out, detected, err := stegoimage.DecodeSecretBackend(&imageOut)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy secret"), out)
+	assert.Equal(t, stegoimage.DefaultBackend, detected.Name())
+}
+
+func newPNG(t *testing.T, width, height int) io.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+
+	return &buf
+}
@@ -0,0 +1,168 @@
+// Package mnemonic encodes binary data (i.e. a partial key) as a list of words, easier than base64 to write
+// on paper or to read over the phone. The words are taken from the English wordlist of BIP-39: every word
+// encodes 11 bits, and it is identified by its first four letters.
+//
+// The first word encodes the size of the data, and the data is followed by a checksum:
+//
+//	size word | data and checksum, 11 bits per word (zero padded)
+package mnemonic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed" // the wordlist is embedded
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	bitsPerWord = 11
+	checksumLen = 3
+	// prefixLen is the number of letters identifying a word
+	prefixLen = 4
+	// MaxSize is the maximum size of the data (encoded by the first word).
+	MaxSize = 1<<bitsPerWord - 1
+)
+
+// ErrChecksum is returned when the words are valid, but their checksum does not match (i.e. a wrong word).
+var ErrChecksum = errors.New("invalid mnemonic checksum")
+
+//go:embed english.txt
+var english string
+
+var (
+	wordlist = strings.Fields(english)
+	// prefixes maps the first letters of every word (the whole word if shorter) to its index
+	prefixes = func() map[string]int {
+		m := make(map[string]int, len(wordlist))
+		for i, word := range wordlist {
+			m[prefix(word)] = i
+		}
+
+		return m
+	}()
+)
+
+func prefix(word string) string {
+	if len(word) > prefixLen {
+		return word[:prefixLen]
+	}
+
+	return word
+}
+
+// Encode returns the words encoding the data.
+func Encode(data []byte) ([]string, error) {
+	if len(data) > MaxSize {
+		return nil, errors.Errorf("data too big for a mnemonic: %d bytes, max %d", len(data), MaxSize)
+	}
+
+	payload := append(append([]byte{}, data...), checksum(data)...)
+	words := make([]string, 0, 1+(len(payload)*8+bitsPerWord-1)/bitsPerWord)
+	words = append(words, wordlist[len(data)])
+
+	var (
+		acc  uint32
+		bits int
+	)
+
+	for _, b := range payload {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+
+		for bits >= bitsPerWord {
+			bits -= bitsPerWord
+			words = append(words, wordlist[acc>>bits&(1<<bitsPerWord-1)])
+		}
+	}
+
+	if bits > 0 {
+		words = append(words, wordlist[acc<<(bitsPerWord-bits)&(1<<bitsPerWord-1)])
+	}
+
+	return words, nil
+}
+
+// Decode returns the data encoded by the words. The words are case insensitive,
+// and they can be abbreviated to their first four letters.
+func Decode(words []string) ([]byte, error) {
+	if len(words) == 0 {
+		return nil, errors.New("empty mnemonic")
+	}
+
+	indexes := make([]int, len(words))
+
+	for i, word := range words {
+		word = strings.ToLower(word)
+
+		index, found := prefixes[prefix(word)]
+		if !found || !strings.HasPrefix(wordlist[index], word) {
+			return nil, errors.Errorf("unknown word '%s' (position %d)", words[i], i+1)
+		}
+
+		indexes[i] = index
+	}
+
+	size := indexes[0]
+	if expected := 1 + ((size+checksumLen)*8+bitsPerWord-1)/bitsPerWord; len(words) != expected {
+		return nil, errors.Errorf("wrong number of words: %d, expected %d", len(words), expected)
+	}
+
+	var (
+		acc  uint32
+		bits int
+	)
+
+	payload := make([]byte, 0, size+checksumLen)
+
+	for _, index := range indexes[1:] {
+		acc = acc<<bitsPerWord | uint32(index)
+		bits += bitsPerWord
+
+		for bits >= 8 && len(payload) < size+checksumLen {
+			bits -= 8
+			payload = append(payload, byte(acc>>bits))
+		}
+	}
+
+	// the padding bits have to be zero
+	if acc&(1<<bits-1) != 0 {
+		return nil, ErrChecksum
+	}
+
+	data := payload[:size]
+	if !bytes.Equal(payload[size:], checksum(data)) {
+		return nil, ErrChecksum
+	}
+
+	return data, nil
+}
+
+// IsMnemonic reports whether the content looks like a list of words, rather than an encoded key.
+func IsMnemonic(content []byte) bool {
+	fields := strings.Fields(string(content))
+	if len(fields) < 2 {
+		return false
+	}
+
+	for _, field := range fields {
+		for _, r := range field {
+			if !unicode.IsLetter(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// checksum returns the first bytes of the SHA-256 of the data, along with its size.
+func checksum(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{byte(len(data) >> 8), byte(len(data))})
+	h.Write(data)
+
+	return h.Sum(nil)[:checksumLen]
+}
@@ -0,0 +1,69 @@
+package mnemonic_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/mnemonic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeDecode(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 7, 8, 32, 76, 286} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i*7 + size)
+		}
+
+		words, err := mnemonic.Encode(data)
+		require.NoError(t, err)
+
+		decoded, err := mnemonic.Decode(words)
+		require.NoError(t, err)
+		assert.Equal(t, data, decoded)
+	}
+
+	_, err := mnemonic.Encode(make([]byte, mnemonic.MaxSize+1))
+	require.Error(t, err)
+}
+
+func Test_Decode(t *testing.T) {
+	words, err := mnemonic.Encode([]byte("a partial key"))
+	require.NoError(t, err)
+
+	// the words can be abbreviated and are case insensitive
+	abbreviated := make([]string, len(words))
+	for i, word := range words {
+		if len(word) > 4 {
+			word = word[:4]
+		}
+
+		abbreviated[i] = strings.ToUpper(word)
+	}
+
+	decoded, err := mnemonic.Decode(abbreviated)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a partial key"), decoded)
+
+	wrong := append([]string{}, words...)
+	wrong[3] = "zoo"
+	if words[3] == "zoo" {
+		wrong[3] = "zero"
+	}
+
+	_, err = mnemonic.Decode(wrong)
+	require.ErrorIs(t, err, mnemonic.ErrChecksum)
+
+	_, err = mnemonic.Decode(append(append([]string{}, words[:3]...), "notaword"))
+	require.Error(t, err)
+
+	_, err = mnemonic.Decode(words[:len(words)-1])
+	require.Error(t, err)
+}
+
+func Test_IsMnemonic(t *testing.T) {
+	assert.True(t, mnemonic.IsMnemonic([]byte("abandon ability able\nabout above\n")))
+	assert.False(t, mnemonic.IsMnemonic([]byte("MjAyNC0wMS0wMQ==")))
+	assert.False(t, mnemonic.IsMnemonic([]byte("abandon")))
+}
@@ -0,0 +1,123 @@
+// Package qr writes the partial keys as QR codes, for paper backups, and reads them back.
+//
+// The codes are generated with the medium error correction level (15%), and decoded offline
+// with gozxing (the Go port of ZXing), so that scans and photos of the printed codes can be read too.
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // the scans can be JPEG images
+	_ "image/png"  // register the PNG decoder
+	"io"
+	"os"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/pkg/errors"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ErrNotFound is returned when the image does not contain a readable QR code.
+var ErrNotFound = errors.New("no QR code found")
+
+// moduleSize is the size in pixels of the modules (the squares) of the generated codes.
+const moduleSize = 8
+
+// EncodePNG returns the PNG image of the QR code of the content.
+func EncodePNG(content []byte) ([]byte, error) {
+	code, err := qrcode.New(string(content), qrcode.Medium)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed generating QR code")
+	}
+
+	png, err := code.PNG(-moduleSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed encoding QR code to PNG")
+	}
+
+	return png, nil
+}
+
+// EncodeSVG returns the SVG image of the QR code of the content.
+func EncodeSVG(content []byte) ([]byte, error) {
+	code, err := qrcode.New(string(content), qrcode.Medium)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed generating QR code")
+	}
+
+	bitmap := code.Bitmap()
+	size := len(bitmap) * moduleSize
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, len(bitmap), len(bitmap))
+	fmt.Fprintf(out, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprint(out, `<path fill="#000000" d="`)
+
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(out, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	fmt.Fprint(out, "\"/>\n</svg>\n")
+
+	return out.Bytes(), nil
+}
+
+// Decode reads the content of the QR code in the image.
+func Decode(r io.Reader) ([]byte, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed decoding image")
+	}
+
+	return DecodeImage(img)
+}
+
+// DecodeImage reads the content of the QR code in the image.
+func DecodeImage(img image.Image) ([]byte, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading image")
+	}
+
+	reader := zxingqr.NewQRCodeReader()
+
+	result, err := reader.Decode(bitmap, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	})
+	if err != nil {
+		// the detector can sample badly a few generated images, that are read as they are
+		var pureErr error
+
+		result, pureErr = reader.Decode(bitmap, map[gozxing.DecodeHintType]interface{}{
+			gozxing.DecodeHintType_PURE_BARCODE: true,
+		})
+		if pureErr != nil {
+			return nil, errors.Wrap(ErrNotFound, err.Error())
+		}
+	}
+
+	return []byte(result.GetText()), nil
+}
+
+// DecodeFile reads the content of the QR code in the image file.
+func DecodeFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed opening file '%s'", filename)
+	}
+	defer f.Close()
+
+	content, err := Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading QR code from '%s'", filename)
+	}
+
+	return content, nil
+}
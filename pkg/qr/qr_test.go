@@ -0,0 +1,152 @@
+package qr_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeDecode(t *testing.T) {
+	contents := []string{
+		"a",
+		"0123456789",
+		"HELLO WORLD 42",
+		base64.StdEncoding.EncodeToString([]byte("a partial key")),
+		strings.Repeat("mixed CONTENT 1234567890 ", 12),
+		base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xfe, 0x01, 0x7a}, 250)),
+		// the detector samples badly this code, that is read as a pure barcode
+		"jlWXBqkrIE7saoALVSoIib65SZqr4zI/KnGCnWFUwg2dy4qWEpa0P5IUsJnzJvNiOYvIggBqiZjAsmym+J3YUT+HPXLlKPnKzjTCNZ3j+RA=",
+	}
+
+	for _, content := range contents {
+		encoded, err := qr.EncodePNG([]byte(content))
+		require.NoError(t, err)
+
+		decoded, err := qr.Decode(bytes.NewReader(encoded))
+		require.NoError(t, err, content)
+		assert.Equal(t, content, string(decoded))
+	}
+
+	svg, err := qr.EncodeSVG([]byte("a"))
+	require.NoError(t, err)
+	assert.Contains(t, string(svg), "<svg")
+}
+
+func Test_DecodeDamaged(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte("a partial key written on paper"))
+
+	encoded, err := qr.EncodePNG([]byte(content))
+	require.NoError(t, err)
+
+	src, err := png.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+
+	// a scan at a different resolution, with a stain in the middle of the code
+	bounds := src.Bounds()
+	img := image.NewGray(image.Rect(0, 0, bounds.Dx()*3/2, bounds.Dy()*3/2))
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			img.Set(x, y, src.At(x*2/3, y*2/3))
+		}
+	}
+
+	center := img.Bounds().Dx() / 2
+	draw.Draw(img, image.Rect(center-12, center-12, center+12, center+12), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	decoded, err := qr.DecodeImage(img)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(decoded))
+
+	// a scan upside down
+	rotated := image.NewGray(img.Bounds())
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			rotated.Set(img.Bounds().Dx()-1-x, img.Bounds().Dy()-1-y, img.At(x, y))
+		}
+	}
+
+	decoded, err = qr.DecodeImage(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(decoded))
+
+	_, err = qr.DecodeImage(image.NewGray(image.Rect(0, 0, 100, 100)))
+	require.ErrorIs(t, err, qr.ErrNotFound)
+}
+
+func Test_DecodePhoto(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte("a partial key written on paper"))
+
+	encoded, err := qr.EncodePNG([]byte(content))
+	require.NoError(t, err)
+
+	src, err := png.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+
+	for _, angle := range []float64{7, 30, -65} {
+		img := photo(src, angle)
+
+		decoded, err := qr.DecodeImage(img)
+		require.NoError(t, err, "angle %v", angle)
+		assert.Equal(t, content, string(decoded), "angle %v", angle)
+	}
+}
+
+// photo returns a photo of the printed code: rotated by the angle (degrees), scaled down, blurred,
+// with an uneven lighting, on a bigger background.
+func photo(src image.Image, angle float64) *image.Gray {
+	bounds := src.Bounds()
+	size := bounds.Dx() * 2
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	scale := 0.8
+
+	// the pixels of the photo are mapped back to the code, rotated around the center
+	sharp := image.NewGray(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x-size/2)/scale, float64(y-size/2)/scale
+			sx := int(dx*cos+dy*sin) + bounds.Dx()/2
+			sy := int(-dx*sin+dy*cos) + bounds.Dy()/2
+
+			gray := uint8(170)
+			if image.Pt(sx, sy).In(bounds) {
+				gray = color.GrayModel.Convert(src.At(sx, sy)).(color.Gray).Y
+			}
+
+			sharp.SetGray(x, y, color.Gray{Y: gray})
+		}
+	}
+
+	img := image.NewGray(sharp.Bounds())
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// 3x3 box blur
+			sum, count := 0, 0
+
+			for by := max(y-1, 0); by <= min(y+1, size-1); by++ {
+				for bx := max(x-1, 0); bx <= min(x+1, size-1); bx++ {
+					sum += int(sharp.GrayAt(bx, by).Y)
+					count++
+				}
+			}
+
+			// darker on the left, and never pure white or black
+			light := 0.6 + 0.4*float64(x)/float64(size)
+			img.SetGray(x, y, color.Gray{Y: uint8(20 + float64(sum/count)*0.8*light)})
+		}
+	}
+
+	return img
+}
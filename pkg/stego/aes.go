@@ -9,7 +9,13 @@ import (
 	"github.com/pkg/errors"
 )
 
-var errCiphertextBlockSizeTooShort = errors.New("ciphertext block size is too short")
+var (
+	errCiphertextBlockSizeTooShort = errors.New("ciphertext block size is too short")
+
+	// ErrAuthenticationFailed is returned when the ciphertext cannot be authenticated,
+	// i.e. the key is wrong or the encrypted content was tampered with.
+	ErrAuthenticationFailed = errors.New("message authentication failed: wrong key or tampered content")
+)
 
 func GenerateMasterKey() ([]byte, error) {
 	key := make([]byte, 32)
@@ -20,29 +26,59 @@ func GenerateMasterKey() ([]byte, error) {
 	return key, nil
 }
 
+// Encrypt encrypts and authenticates the message with AES-GCM.
+// The returned ciphertext is the random nonce followed by the sealed message.
 func Encrypt(key []byte, message []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	return Seal(key, message, nil)
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt.
+// It returns ErrAuthenticationFailed if the key is wrong or the ciphertext was modified.
+func Decrypt(key []byte, cipherText []byte) ([]byte, error) {
+	return Open(key, cipherText, nil)
+}
+
+// Seal works like Encrypt, additionally authenticating (but not encrypting) the additionalData.
+func Seal(key, message, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed getting random 32 bit key")
+		return nil, err
 	}
 
-	// make the cipher text a byte array of size BlockSize + the length of the message
-	cipherText := make([]byte, aes.BlockSize+len(message))
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(message)+gcm.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "failed generating random nonce")
+	}
 
-	// iv is the ciphertext up to the blocksize (16)
-	iv := cipherText[:aes.BlockSize]
-	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, errors.Wrap(err, "failed reading from ciphertext")
+	return gcm.Seal(nonce, nonce, message, additionalData), nil
+}
+
+// Open decrypts a ciphertext produced by Seal with the same additionalData.
+func Open(key, cipherText, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errCiphertextBlockSizeTooShort
 	}
 
-	// encrypt data
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(cipherText[aes.BlockSize:], message)
+	nonce := cipherText[:gcm.NonceSize()]
+	cipherText = cipherText[gcm.NonceSize():]
 
-	return cipherText, nil
+	// decrypt data (not in place: the ciphertext is overwritten if the authentication fails)
+	clearText, err := gcm.Open(make([]byte, 0, len(cipherText)), nonce, cipherText, additionalData)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	return clearText, nil
 }
 
-func Decrypt(key []byte, cipherText []byte) ([]byte, error) {
+// DecryptLegacy decrypts a ciphertext produced by older releases with unauthenticated AES-CFB.
+// A wrong key will not be detected and will produce garbage.
+func DecryptLegacy(key []byte, cipherText []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed creating AES cipher")
@@ -62,3 +98,17 @@ func Decrypt(key []byte, cipherText []byte) ([]byte, error) {
 
 	return cipherText, nil
 }
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating AES cipher")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating GCM cipher")
+	}
+
+	return gcm, nil
+}
@@ -3,6 +3,7 @@ package stego_test
 import (
 	"testing"
 
+	"github.com/enrichman/stegosecrets/pkg/file"
 	"github.com/enrichman/stegosecrets/pkg/stego"
 	"github.com/stretchr/testify/require"
 )
@@ -37,6 +38,50 @@ func Test_EncryptDecryptEmptyMessage(t *testing.T) {
 	require.Equal(t, message, decrypted)
 }
 
+func Test_DecryptWrongKey(t *testing.T) {
+	key, err := stego.GenerateMasterKey()
+	require.NoError(t, err)
+
+	encrypted, err := stego.Encrypt(key, []byte("test message"))
+	require.NoError(t, err)
+
+	wrongKey, err := stego.GenerateMasterKey()
+	require.NoError(t, err)
+
+	decrypted, err := stego.Decrypt(wrongKey, encrypted)
+	require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+	require.Nil(t, decrypted)
+}
+
+func Test_DecryptTampered(t *testing.T) {
+	key, err := stego.GenerateMasterKey()
+	require.NoError(t, err)
+
+	encrypted, err := stego.Encrypt(key, []byte("test message"))
+	require.NoError(t, err)
+
+	encrypted[len(encrypted)-1] ^= 0x01
+
+	decrypted, err := stego.Decrypt(key, encrypted)
+	require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+	require.Nil(t, decrypted)
+}
+
+func Test_DecryptLegacy(t *testing.T) {
+	key, err := file.ReadKey("../../test/assets/p5t3/secret.enc.key")
+	require.NoError(t, err)
+
+	encrypted, err := file.ReadFile("../../test/assets/p5t3/secret.enc")
+	require.NoError(t, err)
+
+	decrypted, err := stego.DecryptLegacy(key, encrypted)
+	require.NoError(t, err)
+
+	expected, err := file.ReadFile("../../test/assets/p5t3/secret")
+	require.NoError(t, err)
+	require.Equal(t, expected, decrypted)
+}
+
 func Benchmark_Encrypt(b *testing.B) {
 	key, err := stego.GenerateMasterKey()
 	if err != nil {
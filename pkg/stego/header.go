@@ -0,0 +1,435 @@
+package stego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderMagic are the bytes every encrypted file produced by stego starts with.
+const HeaderMagic = "STGS"
+
+// HeaderVersion is the current version of the container format.
+// It has to be increased only for changes that older releases cannot read.
+const HeaderVersion byte = 1
+
+// maxHeaderSize protects the parser from allocating huge buffers reading corrupted files.
+const maxHeaderSize = 1<<16 - 1
+
+var (
+	// ErrMissingHeader is returned when the content does not start with the HeaderMagic,
+	// i.e. it was produced by an older release.
+	ErrMissingHeader = errors.New("missing stego header")
+
+	errInvalidHeader = errors.New("invalid stego header")
+)
+
+type CipherSuite byte
+
+const (
+	// CipherAES256GCM encrypts the whole content at once (see Seal).
+	CipherAES256GCM CipherSuite = iota + 1
+	// CipherAES256GCMStream encrypts the content in segments (see EncryptStream).
+	CipherAES256GCMStream
+)
+
+// UnknownSize is the PlaintextSize of a content that was streamed without knowing its size in advance.
+const UnknownSize int64 = -1
+
+func (c CipherSuite) String() string {
+	switch c {
+	case CipherAES256GCM:
+		return "AES-256-GCM"
+	case CipherAES256GCMStream:
+		return "AES-256-GCM-STREAM"
+	default:
+		return "unknown"
+	}
+}
+
+type KDF byte
+
+const (
+	// KDFNone means that the key is a random master key and no derivation is involved.
+	KDFNone KDF = iota
+	// KDFArgon2id means that the key was derived from a passphrase (see DeriveKey).
+	KDFArgon2id
+)
+
+func (k KDF) String() string {
+	switch k {
+	case KDFNone:
+		return "none"
+	case KDFArgon2id:
+		return "argon2id"
+	default:
+		return "unknown"
+	}
+}
+
+// ContentType is the type of the encrypted content.
+type ContentType byte
+
+const (
+	// ContentFile is a single file, or a message.
+	ContentFile ContentType = iota
+	// ContentTar is a directory stored as a tar archive.
+	ContentTar
+	// ContentTarGzip is a directory stored as a tar archive compressed with gzip.
+	ContentTarGzip
+)
+
+func (c ContentType) String() string {
+	switch c {
+	case ContentFile:
+		return "file"
+	case ContentTar:
+		return "tar"
+	case ContentTarGzip:
+		return "tar.gz"
+	default:
+		return "unknown"
+	}
+}
+
+// IsArchive reports whether the content is a directory archive.
+func (c ContentType) IsArchive() bool {
+	return c == ContentTar || c == ContentTarGzip
+}
+
+type KDFParams struct {
+	KDF     KDF
+	Salt    []byte
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// Header describes an encrypted file: how it was encrypted and what it contains.
+// It is stored at the beginning of the file as a list of TLV fields sorted by type, so new fields
+// can be added without breaking older releases (unknown fields are preserved but not interpreted).
+type Header struct {
+	Version       byte
+	Cipher        CipherSuite
+	KDF           KDFParams
+	Filename      string
+	ContentType   ContentType
+	PlaintextSize int64
+	ChunkSize     uint32
+	Parts         uint8
+	Threshold     uint8
+	ShareSetID    ShareSetID
+
+	unknownFields map[byte][]byte
+}
+
+const (
+	fieldEnd byte = iota
+	fieldCipher
+	fieldKDF
+	fieldFilename
+	fieldPlaintextSize
+	fieldChunkSize
+	fieldContentType
+)
+
+// Fields having the mutableField bit set are not authenticated,
+// and they can be changed without re-encrypting the content.
+const (
+	mutableField byte = 0x80
+
+	fieldShareScheme = mutableField | 1
+	fieldShareSetID  = mutableField | 2
+)
+
+// MarshalBinary returns the binary representation of the header.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	return h.marshal(true)
+}
+
+// AuthenticatedData returns the header bytes that have to be authenticated along with the content.
+// The share scheme parameters are informational and they are not bound to the ciphertext,
+// so the master key can be split again without touching the encrypted content.
+func (h *Header) AuthenticatedData() ([]byte, error) {
+	return h.marshal(false)
+}
+
+func (h *Header) fields() map[byte][]byte {
+	fields := map[byte][]byte{}
+	for field, value := range h.unknownFields {
+		fields[field] = value
+	}
+
+	fields[fieldCipher] = []byte{byte(h.Cipher)}
+	fields[fieldKDF] = marshalKDFParams(h.KDF)
+	if h.Filename != "" {
+		fields[fieldFilename] = []byte(h.Filename)
+	}
+
+	if h.PlaintextSize != UnknownSize {
+		fields[fieldPlaintextSize] = binary.BigEndian.AppendUint64(nil, uint64(h.PlaintextSize))
+	}
+
+	if h.ContentType != ContentFile {
+		fields[fieldContentType] = []byte{byte(h.ContentType)}
+	}
+
+	if h.ChunkSize > 0 {
+		fields[fieldChunkSize] = binary.BigEndian.AppendUint32(nil, h.ChunkSize)
+	}
+
+	if h.Parts > 1 {
+		fields[fieldShareScheme] = []byte{h.Parts, h.Threshold}
+	}
+
+	if !h.ShareSetID.IsZero() {
+		fields[fieldShareSetID] = h.ShareSetID[:]
+	}
+
+	return fields
+}
+
+func (h *Header) marshal(withMutableFields bool) ([]byte, error) {
+	fields := h.fields()
+
+	types := make([]byte, 0, len(fields))
+	for field := range fields {
+		if withMutableFields || field&mutableField == 0 {
+			types = append(types, field)
+		}
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	buf := &bytes.Buffer{}
+	for _, field := range types {
+		writeField(buf, field, fields[field])
+	}
+
+	buf.WriteByte(fieldEnd)
+
+	if buf.Len() > maxHeaderSize {
+		return nil, errors.Errorf("header too big: %d bytes", buf.Len())
+	}
+
+	version := h.Version
+	if version == 0 {
+		version = HeaderVersion
+	}
+
+	out := make([]byte, 0, len(HeaderMagic)+3+buf.Len())
+	out = append(out, HeaderMagic...)
+	out = append(out, version)
+	out = binary.BigEndian.AppendUint16(out, uint16(buf.Len()))
+	out = append(out, buf.Bytes()...)
+
+	return out, nil
+}
+
+func writeField(w *bytes.Buffer, field byte, value []byte) {
+	w.WriteByte(field)
+	w.Write(binary.BigEndian.AppendUint16(nil, uint16(len(value))))
+	w.Write(value)
+}
+
+func marshalKDFParams(p KDFParams) []byte {
+	out := []byte{byte(p.KDF)}
+	if p.KDF == KDFNone {
+		return out
+	}
+
+	out = append(out, byte(len(p.Salt)))
+	out = append(out, p.Salt...)
+	out = binary.BigEndian.AppendUint32(out, p.Time)
+	out = binary.BigEndian.AppendUint32(out, p.Memory)
+
+	return append(out, p.Threads)
+}
+
+func unmarshalKDFParams(value []byte) (KDFParams, error) {
+	if len(value) < 1 {
+		return KDFParams{}, errors.Wrap(errInvalidHeader, "empty KDF field")
+	}
+
+	params := KDFParams{KDF: KDF(value[0])}
+	if params.KDF == KDFNone {
+		return params, nil
+	}
+
+	value = value[1:]
+	if len(value) < 1 || len(value) < 1+int(value[0])+9 {
+		return KDFParams{}, errors.Wrap(errInvalidHeader, "KDF field too short")
+	}
+
+	saltLen := int(value[0])
+	params.Salt = value[1 : 1+saltLen]
+	value = value[1+saltLen:]
+	params.Time = binary.BigEndian.Uint32(value[0:4])
+	params.Memory = binary.BigEndian.Uint32(value[4:8])
+	params.Threads = value[8]
+
+	return params, nil
+}
+
+// HasHeader reports whether the content starts with the HeaderMagic.
+func HasHeader(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(HeaderMagic))
+}
+
+// ReadHeader reads and parses the header from the reader, consuming exactly the header bytes.
+// It returns ErrMissingHeader if the content does not start with the HeaderMagic.
+func ReadHeader(r io.Reader) (*Header, error) {
+	prefix := make([]byte, len(HeaderMagic)+3)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrMissingHeader
+		}
+
+		return nil, errors.Wrap(err, "failed reading header")
+	}
+
+	if !HasHeader(prefix) {
+		return nil, ErrMissingHeader
+	}
+
+	header := &Header{Version: prefix[len(HeaderMagic)], PlaintextSize: UnknownSize}
+	if header.Version > HeaderVersion {
+		return nil, errors.Errorf("unsupported format version %d: upgrade stego", header.Version)
+	}
+
+	fields := make([]byte, binary.BigEndian.Uint16(prefix[len(HeaderMagic)+1:]))
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return nil, errors.Wrap(err, "failed reading header fields")
+	}
+
+	if err := header.unmarshalFields(fields); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+func (h *Header) unmarshalFields(fields []byte) error {
+	for len(fields) > 0 {
+		field := fields[0]
+		if field == fieldEnd {
+			return nil
+		}
+
+		if len(fields) < 3 {
+			return errors.Wrap(errInvalidHeader, "truncated field")
+		}
+
+		size := int(binary.BigEndian.Uint16(fields[1:3]))
+		if len(fields) < 3+size {
+			return errors.Wrapf(errInvalidHeader, "truncated field %d", field)
+		}
+
+		value := fields[3 : 3+size]
+		fields = fields[3+size:]
+
+		if err := h.unmarshalField(field, value); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(errInvalidHeader, "missing end of header")
+}
+
+func (h *Header) unmarshalField(field byte, value []byte) error {
+	var err error
+
+	switch field {
+	case fieldCipher:
+		if len(value) != 1 {
+			return errors.Wrap(errInvalidHeader, "invalid cipher field")
+		}
+
+		h.Cipher = CipherSuite(value[0])
+	case fieldKDF:
+		h.KDF, err = unmarshalKDFParams(value)
+	case fieldFilename:
+		h.Filename = string(value)
+	case fieldPlaintextSize:
+		if len(value) != 8 {
+			return errors.Wrap(errInvalidHeader, "invalid plaintext size field")
+		}
+
+		h.PlaintextSize = int64(binary.BigEndian.Uint64(value))
+	case fieldChunkSize:
+		if len(value) != 4 {
+			return errors.Wrap(errInvalidHeader, "invalid chunk size field")
+		}
+
+		h.ChunkSize = binary.BigEndian.Uint32(value)
+	case fieldContentType:
+		if len(value) != 1 {
+			return errors.Wrap(errInvalidHeader, "invalid content type field")
+		}
+
+		h.ContentType = ContentType(value[0])
+	case fieldShareScheme:
+		if len(value) != 2 {
+			return errors.Wrap(errInvalidHeader, "invalid share scheme field")
+		}
+
+		h.Parts, h.Threshold = value[0], value[1]
+	case fieldShareSetID:
+		if len(value) != ShareSetIDSize {
+			return errors.Wrap(errInvalidHeader, "invalid share set ID field")
+		}
+
+		copy(h.ShareSetID[:], value)
+	default:
+		// unknown fields were added by newer releases: keep them to write them back untouched
+		if h.unknownFields == nil {
+			h.unknownFields = map[byte][]byte{}
+		}
+
+		h.unknownFields[field] = value
+	}
+
+	return err
+}
+
+// RewriteHeader copies the encrypted content from the reader to the writer, updating its header.
+// Only the fields that are not authenticated (i.e. the share scheme) can be changed,
+// because the encrypted content is not touched.
+func RewriteHeader(r io.Reader, w io.Writer, update func(*Header)) error {
+	header, err := ReadHeader(r)
+	if err != nil {
+		return err
+	}
+
+	authenticatedData, err := header.AuthenticatedData()
+	if err != nil {
+		return err
+	}
+
+	update(header)
+
+	updatedAuthenticatedData, err := header.AuthenticatedData()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(authenticatedData, updatedAuthenticatedData) {
+		return errors.New("cannot change authenticated header fields without re-encrypting the content")
+	}
+
+	headerBytes, err := header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(headerBytes); err != nil {
+		return errors.Wrap(err, "failed writing header")
+	}
+
+	_, err = io.Copy(w, r)
+
+	return errors.Wrap(err, "failed copying encrypted content")
+}
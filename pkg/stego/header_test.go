@@ -0,0 +1,102 @@
+package stego_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HeaderMarshalRead(t *testing.T) {
+	header := &stego.Header{
+		Cipher:        stego.CipherAES256GCM,
+		KDF:           stego.KDFParams{KDF: stego.KDFNone},
+		Filename:      "mysecret.txt",
+		PlaintextSize: 42,
+		Parts:         5,
+		Threshold:     3,
+	}
+
+	headerBytes, err := header.MarshalBinary()
+	require.NoError(t, err)
+	assert.True(t, stego.HasHeader(headerBytes))
+
+	content := append(headerBytes, []byte("ciphertext")...)
+	reader := bytes.NewReader(content)
+
+	parsed, err := stego.ReadHeader(reader)
+	require.NoError(t, err)
+
+	header.Version = stego.HeaderVersion
+	assert.Equal(t, header, parsed)
+	assert.Equal(t, len("ciphertext"), reader.Len())
+}
+
+func Test_HeaderAuthenticatedDataIgnoresShareScheme(t *testing.T) {
+	header := &stego.Header{Cipher: stego.CipherAES256GCM, Parts: 5, Threshold: 3}
+
+	authenticatedData, err := header.AuthenticatedData()
+	require.NoError(t, err)
+
+	header.Parts, header.Threshold = 7, 4
+
+	resharedAuthenticatedData, err := header.AuthenticatedData()
+	require.NoError(t, err)
+	assert.Equal(t, authenticatedData, resharedAuthenticatedData)
+}
+
+func Test_ReadHeaderMissing(t *testing.T) {
+	_, err := stego.ReadHeader(bytes.NewReader([]byte("legacy content without header")))
+	require.ErrorIs(t, err, stego.ErrMissingHeader)
+
+	_, err = stego.ReadHeader(bytes.NewReader([]byte{}))
+	require.ErrorIs(t, err, stego.ErrMissingHeader)
+}
+
+func Test_ReadHeaderUnsupportedVersion(t *testing.T) {
+	header := &stego.Header{Version: stego.HeaderVersion + 1, Cipher: stego.CipherAES256GCM}
+
+	headerBytes, err := header.MarshalBinary()
+	require.NoError(t, err)
+
+	_, err = stego.ReadHeader(bytes.NewReader(headerBytes))
+	require.Error(t, err)
+}
+
+func Test_HeaderMarshalReadKDF(t *testing.T) {
+	params, err := stego.NewArgon2idParams()
+	require.NoError(t, err)
+
+	header := &stego.Header{Cipher: stego.CipherAES256GCMStream, KDF: params, PlaintextSize: stego.UnknownSize}
+
+	headerBytes, err := header.MarshalBinary()
+	require.NoError(t, err)
+
+	parsed, err := stego.ReadHeader(bytes.NewReader(headerBytes))
+	require.NoError(t, err)
+	assert.Equal(t, params, parsed.KDF)
+}
+
+func Test_HeaderMarshalReadContentType(t *testing.T) {
+	header := &stego.Header{Cipher: stego.CipherAES256GCMStream, ContentType: stego.ContentTarGzip, PlaintextSize: stego.UnknownSize}
+
+	headerBytes, err := header.MarshalBinary()
+	require.NoError(t, err)
+
+	parsed, err := stego.ReadHeader(bytes.NewReader(headerBytes))
+	require.NoError(t, err)
+	assert.Equal(t, stego.ContentTarGzip, parsed.ContentType)
+	assert.True(t, parsed.ContentType.IsArchive())
+
+	// the content type is authenticated
+	authenticatedData, err := header.AuthenticatedData()
+	require.NoError(t, err)
+
+	header.ContentType = stego.ContentFile
+
+	fileAuthenticatedData, err := header.AuthenticatedData()
+	require.NoError(t, err)
+	assert.NotEqual(t, authenticatedData, fileAuthenticatedData)
+}
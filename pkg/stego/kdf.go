@@ -0,0 +1,64 @@
+package stego
+
+import (
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id default parameters, as recommended by RFC 9106 for memory constrained environments.
+const (
+	argon2idTime    = 3
+	argon2idMemory  = 64 * 1024 // KiB
+	argon2idThreads = 4
+	argon2idSaltLen = 16
+
+	// maxArgon2idMemory, maxArgon2idTime and maxArgon2idThreads limit the resources used to derive a key
+	// from the parameters read from a file (the header of an encrypted file, or a wrapped partial key).
+	maxArgon2idMemory  = 4 * 1024 * 1024 // KiB
+	maxArgon2idTime    = 64
+	maxArgon2idThreads = 64
+)
+
+// NewArgon2idParams returns the default Argon2id parameters with a new random salt.
+func NewArgon2idParams() (KDFParams, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFParams{}, errors.Wrap(err, "failed generating random salt")
+	}
+
+	return KDFParams{
+		KDF:     KDFArgon2id,
+		Salt:    salt,
+		Time:    argon2idTime,
+		Memory:  argon2idMemory,
+		Threads: argon2idThreads,
+	}, nil
+}
+
+// DeriveKey derives a 32 bytes key from the passphrase.
+func DeriveKey(passphrase []byte, params KDFParams) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+
+	switch params.KDF {
+	case KDFArgon2id:
+		if len(params.Salt) < 8 ||
+			params.Time == 0 || params.Time > maxArgon2idTime ||
+			params.Threads == 0 || params.Threads > maxArgon2idThreads ||
+			params.Memory == 0 || params.Memory > maxArgon2idMemory {
+			return nil, errors.Errorf(
+				"invalid argon2id parameters: salt %d bytes, time %d, memory %d KiB, threads %d",
+				len(params.Salt), params.Time, params.Memory, params.Threads,
+			)
+		}
+
+		return argon2.IDKey(passphrase, params.Salt, params.Time, params.Memory, params.Threads, 32), nil
+	case KDFNone:
+		return nil, errors.New("the key was not derived from a passphrase")
+	default:
+		return nil, errors.Errorf("unsupported KDF %d", params.KDF)
+	}
+}
@@ -0,0 +1,68 @@
+package stego_test
+
+import (
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DeriveKey(t *testing.T) {
+	params, err := stego.NewArgon2idParams()
+	require.NoError(t, err)
+	assert.Equal(t, stego.KDFArgon2id, params.KDF)
+	assert.Len(t, params.Salt, 16)
+
+	key, err := stego.DeriveKey([]byte("correct horse battery staple"), params)
+	require.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	again, err := stego.DeriveKey([]byte("correct horse battery staple"), params)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+
+	other, err := stego.DeriveKey([]byte("wrong passphrase"), params)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	// a different salt derives a different key
+	otherParams, err := stego.NewArgon2idParams()
+	require.NoError(t, err)
+
+	other, err = stego.DeriveKey([]byte("correct horse battery staple"), otherParams)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func Test_DeriveKeyInvalid(t *testing.T) {
+	params, err := stego.NewArgon2idParams()
+	require.NoError(t, err)
+
+	_, err = stego.DeriveKey(nil, params)
+	require.Error(t, err)
+
+	_, err = stego.DeriveKey([]byte("passphrase"), stego.KDFParams{KDF: stego.KDFNone})
+	require.Error(t, err)
+
+	// the parameters read from a file are limited, not to exhaust the memory or the CPU
+	tt := map[string]func(p *stego.KDFParams){
+		"short salt":   func(p *stego.KDFParams) { p.Salt = p.Salt[:4] },
+		"zero time":    func(p *stego.KDFParams) { p.Time = 0 },
+		"time 65":      func(p *stego.KDFParams) { p.Time = 65 },
+		"huge time":    func(p *stego.KDFParams) { p.Time = 1 << 31 },
+		"zero threads": func(p *stego.KDFParams) { p.Threads = 0 },
+		"threads 65":   func(p *stego.KDFParams) { p.Threads = 65 },
+		"threads 255":  func(p *stego.KDFParams) { p.Threads = 255 },
+		"zero memory":  func(p *stego.KDFParams) { p.Memory = 0 },
+		"huge memory":  func(p *stego.KDFParams) { p.Memory = 1 << 30 },
+	}
+
+	for name, tamper := range tt {
+		invalid := params
+		tamper(&invalid)
+
+		_, err = stego.DeriveKey([]byte("passphrase"), invalid)
+		require.Error(t, err, name)
+	}
+}
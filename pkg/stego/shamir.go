@@ -1,17 +1,76 @@
 package stego
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"sort"
 
 	shamir "github.com/corvus-ch/shamir"
 	"github.com/pkg/errors"
 )
 
+const (
+	// PartVersion1 is the version of the parts created by older releases, without a share set ID.
+	PartVersion1 byte = '1'
+	// PartVersion2 adds the share set ID.
+	PartVersion2 byte = '2'
+	// PartVersion3 adds the MAC of the part, used to identify the corrupted or forged parts while combining them.
+	PartVersion3 byte = '3'
+	// PartVersion4 has the same fields of PartVersion3, but the secret is split over a prime field:
+	// the parts can be verified against public commitments (see SplitVerifiable).
+	PartVersion4 byte = '4'
+)
+
+const (
+	// PartMACSize is the size of the MAC of the parts.
+	PartMACSize = 16
+	// macKeySize is the size of the random key split along with the secret. The MAC is keyed with both,
+	// because every byte of the secret is split independently: the MAC of a part does not leak anything
+	// about the secret, and any corrupted byte of the recovered secret invalidates all the MACs.
+	macKeySize = 32
+	// maxCombinations limits the subsets of parts tried looking for the corrupted ones.
+	maxCombinations = 1 << 14
+)
+
+// ErrBadParts is returned when the parts cannot be combined because some of them are corrupted or forged.
+var ErrBadParts = errors.New("corrupted or forged parts")
+
+// ShareSetIDSize is the size of the random ID shared by all the parts of a split.
+const ShareSetIDSize = 8
+
+// ShareSetID identifies all the parts created by the same split of a secret.
+// Parts of different sets cannot be combined together.
+type ShareSetID [ShareSetIDSize]byte
+
+func (s ShareSetID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsZero reports whether the ID is missing (i.e. parts created by older releases).
+func (s ShareSetID) IsZero() bool {
+	return s == ShareSetID{}
+}
+
+func NewShareSetID() (ShareSetID, error) {
+	id := ShareSetID{}
+	if _, err := rand.Read(id[:]); err != nil {
+		return id, errors.Wrap(err, "failed generating share set ID")
+	}
+
+	return id, nil
+}
+
 type Part struct {
 	Version   byte
 	Parts     byte
 	Threshold byte
 	Tag       byte
+	SetID     ShareSetID
+	MAC       []byte
 	Content   []byte
 }
 
@@ -20,13 +79,52 @@ func NewPartFromContent(content []byte) (Part, error) {
 		return Part{}, errors.New("invalid part: ot enough content bytes")
 	}
 
-	return NewPart(
-		content[0],
-		content[1],
-		content[2],
-		content[3],
-		content[4:],
-	), nil
+	switch content[0] {
+	case PartVersion1:
+		return NewPart(
+			content[0],
+			content[1],
+			content[2],
+			content[3],
+			content[4:],
+		), nil
+	case PartVersion2:
+		if len(content) < 5+ShareSetIDSize {
+			return Part{}, errors.New("invalid part: not enough content bytes")
+		}
+
+		part := NewPart(
+			content[0],
+			content[1],
+			content[2],
+			content[3],
+			content[4+ShareSetIDSize:],
+		)
+		copy(part.SetID[:], content[4:4+ShareSetIDSize])
+
+		return part, nil
+	case PartVersion3, PartVersion4:
+		headerLen := 4 + ShareSetIDSize + PartMACSize
+		if len(content) < headerLen+1 {
+			return Part{}, errors.New("invalid part: not enough content bytes")
+		}
+
+		part := NewPart(
+			content[0],
+			content[1],
+			content[2],
+			content[3],
+			content[headerLen:],
+		)
+		copy(part.SetID[:], content[4:4+ShareSetIDSize])
+		part.MAC = content[4+ShareSetIDSize : headerLen]
+
+		return part, nil
+	case PartWrapped:
+		return Part{}, ErrWrappedPart
+	default:
+		return Part{}, errors.Errorf("invalid part: unknown version %d", content[0])
+	}
 }
 
 func NewPart(version, parts, threshold, tag byte, content []byte) Part {
@@ -40,55 +138,289 @@ func NewPart(version, parts, threshold, tag byte, content []byte) Part {
 }
 
 func (p Part) Bytes() []byte {
-	return append([]byte{
+	bb := []byte{
 		p.Version,
 		p.Parts,
 		p.Threshold,
 		p.Tag,
-	}, p.Content...)
+	}
+
+	if p.Version != PartVersion1 {
+		bb = append(bb, p.SetID[:]...)
+	}
+
+	if p.hasMAC() {
+		bb = append(bb, p.MAC...)
+	}
+
+	return append(bb, p.Content...)
+}
+
+func (p Part) hasMAC() bool {
+	return p.Version == PartVersion3 || p.Version == PartVersion4
+}
+
+// computeMAC returns the MAC of the part (all its fields, but the MAC).
+func (p Part) computeMAC(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte{p.Version, p.Parts, p.Threshold, p.Tag})
+	mac.Write(p.SetID[:])
+	mac.Write(p.Content)
+
+	return mac.Sum(nil)[:PartMACSize]
 }
 
 func (p Part) Base64() string {
 	return base64.StdEncoding.EncodeToString(p.Bytes())
 }
 
+// Split splits the secret into parts, with a new random share set ID.
 func Split(secret []byte, parts, threshold uint8) ([]Part, error) {
-	partsMap, err := shamir.Split(secret, int(parts), int(threshold))
+	setID, err := NewShareSetID()
+	if err != nil {
+		return nil, err
+	}
+
+	macKey := make([]byte, macKeySize)
+	if _, err := rand.Read(macKey); err != nil {
+		return nil, errors.Wrap(err, "failed generating MAC key")
+	}
+
+	keyed := append(append([]byte{}, secret...), macKey...)
+
+	partsMap, err := shamir.Split(keyed, int(parts), int(threshold))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed splitting secret")
 	}
 
 	keys := []Part{}
 	for k, v := range partsMap {
-		keys = append(keys, NewPart(
-			'1',
+		part := NewPart(
+			PartVersion3,
 			parts,
 			threshold,
 			k,
 			v,
-		))
+		)
+		part.SetID = setID
+		part.MAC = part.computeMAC(keyed)
+
+		keys = append(keys, part)
 	}
 
 	return keys, nil
 }
 
+// Combine reconstructs the secret from the parts. The corrupted or forged parts are excluded, if possible
+// (see CombineChecked).
 func Combine(parts []Part) ([]byte, error) {
-	combinedMap := map[byte][]byte{}
+	secret, _, err := CombineChecked(parts)
+
+	return secret, err
+}
+
+// CombineChecked reconstructs the secret from the parts, returning also the parts excluded because
+// corrupted or forged. They are identified by their MAC only if more parts than the threshold are provided:
+// with exactly the threshold parts a bad part makes the combination fail with ErrBadParts.
+// The same part can be provided more than once (i.e. as key and as image): different parts with the same tag
+// are all tried, and the bad ones are excluded.
+// The parts created by older releases have no MAC, and they are combined without any check: different parts
+// with the same tag make the combination fail with ErrBadParts.
+func CombineChecked(parts []Part) ([]byte, []Part, error) {
+	if len(parts) == 0 {
+		return nil, nil, errors.New("no parts provided")
+	}
+
+	unique := make([]Part, 0, len(parts))
+	tags := map[byte]bool{}
+	conflicting := []byte{}
+	withMAC := true
+
 	for _, p := range parts {
-		combinedMap[p.Tag] = p.Content
+		if p.SetID != parts[0].SetID {
+			return nil, nil, errors.Errorf(
+				"parts belong to different share sets: %s, %s",
+				parts[0].SetID, p.SetID,
+			)
+		}
+
+		// the parts of different versions are split over different fields, they cannot be combined
+		if p.Version != parts[0].Version {
+			return nil, nil, errors.Errorf(
+				"parts have different versions: %c, %c",
+				parts[0].Version, p.Version,
+			)
+		}
+
+		if containsPart(unique, p) {
+			continue
+		}
+
+		if tags[p.Tag] {
+			conflicting = append(conflicting, p.Tag)
+		}
+
+		tags[p.Tag] = true
+		unique = append(unique, p)
+		withMAC = withMAC && p.hasMAC()
 	}
 
-	if len(combinedMap) < int(parts[0].Threshold) {
-		return nil, errors.Errorf(
+	threshold := int(parts[0].Threshold)
+	if len(tags) < threshold {
+		return nil, nil, errors.Errorf(
 			"not enough parts provided: parts %d, threshold %d",
-			len(combinedMap), parts[0].Threshold,
+			len(tags), threshold,
 		)
 	}
 
-	res, err := shamir.Combine(combinedMap)
+	sort.SliceStable(unique, func(i, j int) bool { return unique[i].Tag < unique[j].Tag })
+
+	if !withMAC {
+		if len(conflicting) > 0 {
+			return nil, nil, errors.Wrapf(ErrBadParts, "different parts with the same tag %d", conflicting[0])
+		}
+
+		res, err := combine(unique)
+
+		return res, nil, err
+	}
+
+	return combineWithMAC(unique, threshold)
+}
+
+// containsPart reports whether the same part (tag, content and MAC) is in the parts.
+func containsPart(parts []Part, part Part) bool {
+	for _, p := range parts {
+		if p.Tag == part.Tag && bytes.Equal(p.Content, part.Content) && hmac.Equal(p.MAC, part.MAC) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// combineWithMAC looks for a subset of threshold parts (with different tags) recovering a secret that validates
+// the MAC of at least threshold parts: the parts with an invalid MAC are the bad ones.
+func combineWithMAC(parts []Part, threshold int) ([]byte, []Part, error) {
+	var (
+		secret []byte
+		bad    []Part
+	)
+
+	tried := 0
+
+	combinations(len(parts), threshold, func(indexes []int) bool {
+		subset := make([]Part, len(indexes))
+		tags := map[byte]bool{}
+
+		for i, index := range indexes {
+			subset[i] = parts[index]
+			tags[parts[index].Tag] = true
+		}
+
+		// two parts with the same tag cannot be combined
+		if len(tags) < threshold {
+			return true
+		}
+
+		tried++
+		if tried > maxCombinations {
+			return false
+		}
+
+		// a bad part can make the combination fail as well
+		res, err := combine(subset)
+		if err != nil || len(res) <= macKeySize {
+			return true
+		}
+
+		invalid := []Part{}
+		for _, p := range parts {
+			if !hmac.Equal(p.MAC, p.computeMAC(res)) {
+				invalid = append(invalid, p)
+			}
+		}
+
+		if len(parts)-len(invalid) < threshold {
+			return true
+		}
+
+		secret, bad = res[:len(res)-macKeySize], invalid
+
+		return false
+	})
+
+	if secret != nil {
+		return secret, bad, nil
+	}
+
+	tags := map[byte]bool{}
+	for _, p := range parts {
+		tags[p.Tag] = true
+	}
+
+	if len(tags) == threshold {
+		return nil, nil, errors.Wrap(ErrBadParts, "provide more parts than the threshold to identify them")
+	}
+
+	return nil, nil, errors.Wrapf(ErrBadParts, "not enough valid parts among %d", len(parts))
+}
+
+func combine(parts []Part) ([]byte, error) {
+	if parts[0].Version == PartVersion4 {
+		return combineVerifiable(parts)
+	}
+
+	partsMap := map[byte][]byte{}
+	for _, p := range parts {
+		partsMap[p.Tag] = p.Content
+	}
+
+	res, err := shamir.Combine(partsMap)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed combining secret")
 	}
 
 	return res, nil
 }
+
+// combinations calls the function with the indexes of every subset of k elements out of n,
+// in lexicographic order, until it returns false.
+func combinations(n, k int, f func([]int) bool) {
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	for {
+		if !f(indexes) {
+			return
+		}
+
+		i := k - 1
+		for i >= 0 && indexes[i] == n-k+i {
+			i--
+		}
+
+		if i < 0 {
+			return
+		}
+
+		indexes[i]++
+		for j := i + 1; j < k; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
+
+// Reshare combines the parts recovering the secret, and splits it again in a new share set
+// with different parts and threshold. The secret is never returned, and the old parts cannot
+// be combined with the new ones.
+func Reshare(parts []Part, newParts, newThreshold uint8) ([]Part, error) {
+	secret, err := Combine(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Split(secret, newParts, newThreshold)
+}
@@ -0,0 +1,155 @@
+package stego_test
+
+import (
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SplitCombine(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, err := stego.Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, parts, 5)
+
+	for _, part := range parts {
+		assert.Equal(t, stego.PartVersion3, part.Version)
+		assert.Equal(t, parts[0].SetID, part.SetID)
+
+		parsed, err := stego.NewPartFromContent(part.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, part, parsed)
+	}
+
+	combined, err := stego.Combine(parts[1:4])
+	require.NoError(t, err)
+	assert.Equal(t, secret, combined)
+
+	_, err = stego.Combine(parts[:2])
+	require.Error(t, err)
+}
+
+func Test_Reshare(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, err := stego.Split(secret, 3, 2)
+	require.NoError(t, err)
+
+	newParts, err := stego.Reshare(parts[:2], 5, 4)
+	require.NoError(t, err)
+	require.Len(t, newParts, 5)
+	assert.NotEqual(t, parts[0].SetID, newParts[0].SetID)
+
+	combined, err := stego.Combine(newParts[:4])
+	require.NoError(t, err)
+	assert.Equal(t, secret, combined)
+
+	// parts of different share sets cannot be mixed
+	_, err = stego.Combine(append(newParts[:3], parts[0]))
+	require.Error(t, err)
+}
+
+func Test_CombineBadParts(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, err := stego.Split(secret, 5, 3)
+	require.NoError(t, err)
+
+	corrupted := parts[1]
+	corrupted.Content = append([]byte{}, corrupted.Content...)
+	corrupted.Content[0] ^= 1
+
+	forged := parts[3]
+	forged.Content = make([]byte, len(forged.Content))
+
+	// the bad parts are identified and excluded if there are enough good parts
+	combined, bad, err := stego.CombineChecked([]stego.Part{parts[0], corrupted, parts[2], forged, parts[4]})
+	require.NoError(t, err)
+	assert.Equal(t, secret, combined)
+	assert.ElementsMatch(t, []stego.Part{corrupted, forged}, bad)
+
+	// with the threshold parts the bad one cannot be identified
+	_, err = stego.Combine([]stego.Part{parts[0], corrupted, parts[2]})
+	require.ErrorIs(t, err, stego.ErrBadParts)
+
+	_, err = stego.Combine([]stego.Part{parts[0], corrupted, parts[2], forged})
+	require.ErrorIs(t, err, stego.ErrBadParts)
+}
+
+func Test_CombineDuplicateTags(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, err := stego.Split(secret, 5, 3)
+	require.NoError(t, err)
+
+	// the same part (i.e. as key and as image) is counted once
+	_, err = stego.Combine([]stego.Part{parts[0], parts[0], parts[1]})
+	require.Error(t, err)
+
+	combined, bad, err := stego.CombineChecked([]stego.Part{parts[0], parts[0], parts[1], parts[2]})
+	require.NoError(t, err)
+	assert.Equal(t, secret, combined)
+	assert.Empty(t, bad)
+
+	// different parts with the same tag are both tried, and the bad one is excluded
+	corrupted := parts[1]
+	corrupted.Content = append([]byte{}, corrupted.Content...)
+	corrupted.Content[0] ^= 1
+
+	for _, duplicates := range [][]stego.Part{
+		{parts[0], parts[1], corrupted, parts[2]},
+		{parts[0], corrupted, parts[1], parts[2]},
+	} {
+		combined, bad, err = stego.CombineChecked(duplicates)
+		require.NoError(t, err)
+		assert.Equal(t, secret, combined)
+		assert.Equal(t, []stego.Part{corrupted}, bad)
+	}
+
+	// without the MAC the right one cannot be identified
+	legacy := make([]stego.Part, len(parts))
+	for i, p := range parts {
+		legacy[i] = stego.NewPart(stego.PartVersion2, p.Parts, p.Threshold, p.Tag, p.Content)
+		legacy[i].SetID = p.SetID
+	}
+
+	expected, err := stego.Combine(legacy[:3])
+	require.NoError(t, err)
+
+	combined, err = stego.Combine([]stego.Part{legacy[0], legacy[0], legacy[1], legacy[2]})
+	require.NoError(t, err)
+	assert.Equal(t, expected, combined)
+
+	legacyCorrupted := legacy[1]
+	legacyCorrupted.Content = corrupted.Content
+
+	_, err = stego.Combine([]stego.Part{legacy[0], legacy[1], legacyCorrupted, legacy[2]})
+	require.ErrorIs(t, err, stego.ErrBadParts)
+	assert.ErrorContains(t, err, "different parts with the same tag")
+}
+
+func Test_CombineMixedVersions(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, err := stego.Split(secret, 3, 2)
+	require.NoError(t, err)
+
+	verifiable, _, err := stego.SplitVerifiable(secret, 3, 2)
+	require.NoError(t, err)
+
+	// a part of the same share set with a different version (i.e. forged, or re-encoded by a buggy tool)
+	mixed := verifiable[1]
+	mixed.SetID = parts[0].SetID
+
+	for _, p := range [][]stego.Part{
+		{parts[0], mixed},
+		{mixed, parts[0]},
+		{parts[0], parts[1], mixed},
+	} {
+		_, err = stego.Combine(p)
+		require.ErrorContains(t, err, "parts have different versions")
+	}
+}
@@ -0,0 +1,232 @@
+package stego
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChunkSize is the size of the plaintext segments encrypted by EncryptStream.
+	DefaultChunkSize = 64 * 1024
+
+	maxChunkSize = 16 * 1024 * 1024
+
+	// the nonce of every segment is the random prefix, the segment counter and the last segment flag
+	noncePrefixSize = 7
+)
+
+var errStreamClosed = errors.New("stream already closed")
+
+// EncryptStream returns a writer encrypting the written data in segments of chunkSize bytes with AES-GCM,
+// following the STREAM construction: every segment is authenticated with its position and a flag marking
+// the last one, so reordering, removing or truncating segments is detected.
+// The writer has to be closed to flush the last segment.
+func EncryptStream(key, additionalData []byte, chunkSize int, w io.Writer) (io.WriteCloser, error) {
+	if err := validateChunkSize(chunkSize); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = io.ReadFull(rand.Reader, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "failed generating random nonce")
+	}
+
+	if _, err = w.Write(noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "failed writing nonce")
+	}
+
+	return &streamWriter{
+		segmenter: newSegmenter(gcm, noncePrefix, additionalData),
+		w:         w,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize+gcm.Overhead()),
+	}, nil
+}
+
+// DecryptStream returns a reader decrypting the content written by EncryptStream.
+// The reader returns ErrAuthenticationFailed as soon as a segment cannot be authenticated,
+// so the data read before the error must not be trusted until io.EOF is reached.
+func DecryptStream(key, additionalData []byte, chunkSize int, r io.Reader) (io.Reader, error) {
+	if err := validateChunkSize(chunkSize); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = io.ReadFull(r, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "failed reading nonce")
+	}
+
+	return &streamReader{
+		segmenter: newSegmenter(gcm, noncePrefix, additionalData),
+		r:         bufio.NewReaderSize(r, chunkSize+gcm.Overhead()+1),
+		segment:   make([]byte, chunkSize+gcm.Overhead()),
+	}, nil
+}
+
+func validateChunkSize(chunkSize int) error {
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return errors.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	return nil
+}
+
+type segmenter struct {
+	aead           cipher.AEAD
+	nonce          []byte
+	additionalData []byte
+	counter        uint64
+}
+
+func newSegmenter(aead cipher.AEAD, noncePrefix, additionalData []byte) segmenter {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, noncePrefix)
+
+	return segmenter{aead: aead, nonce: nonce, additionalData: additionalData}
+}
+
+func (s *segmenter) next(last bool) ([]byte, error) {
+	if s.counter > math.MaxUint32 {
+		return nil, errors.New("stream too long")
+	}
+
+	binary.BigEndian.PutUint32(s.nonce[noncePrefixSize:], uint32(s.counter))
+	s.nonce[len(s.nonce)-1] = 0
+
+	if last {
+		s.nonce[len(s.nonce)-1] = 1
+	}
+
+	s.counter++
+
+	return s.nonce, nil
+}
+
+type streamWriter struct {
+	segmenter
+	w         io.Writer
+	chunkSize int
+	buf       []byte
+	closed    bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errStreamClosed
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		// a full segment is flushed only when more data is available,
+		// because the last segment needs to be sealed with the last flag on Close
+		if len(s.buf) == s.chunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):s.chunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	return s.flush(true)
+}
+
+func (s *streamWriter) flush(last bool) error {
+	nonce, err := s.next(last)
+	if err != nil {
+		return err
+	}
+
+	sealed := s.aead.Seal(s.buf[:0], nonce, s.buf, s.additionalData)
+
+	if _, err := s.w.Write(sealed); err != nil {
+		return errors.Wrap(err, "failed writing encrypted segment")
+	}
+
+	s.buf = s.buf[:0]
+
+	return nil
+}
+
+type streamReader struct {
+	segmenter
+	r       *bufio.Reader
+	segment []byte
+	buf     []byte
+	done    bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		if err := s.readSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+
+	return n, nil
+}
+
+func (s *streamReader) readSegment() error {
+	n, err := io.ReadFull(s.r, s.segment)
+
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		s.done = true
+	case err != nil:
+		return errors.Wrap(err, "failed reading encrypted segment")
+	default:
+		// a full segment is the last one only if nothing follows
+		if _, err := s.r.Peek(1); errors.Is(err, io.EOF) {
+			s.done = true
+		}
+	}
+
+	nonce, err := s.next(s.done)
+	if err != nil {
+		return err
+	}
+
+	s.buf, err = s.aead.Open(s.segment[:0], nonce, s.segment[:n], s.additionalData)
+	if err != nil {
+		return ErrAuthenticationFailed
+	}
+
+	return nil
+}
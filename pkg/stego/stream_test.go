@@ -0,0 +1,88 @@
+package stego_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = 16
+
+func encryptStream(t *testing.T, key, message []byte) []byte {
+	t.Helper()
+
+	encrypted := &bytes.Buffer{}
+
+	stream, err := stego.EncryptStream(key, []byte("header"), testChunkSize, encrypted)
+	require.NoError(t, err)
+
+	_, err = stream.Write(message)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	return encrypted.Bytes()
+}
+
+func decryptStream(key, encrypted []byte) ([]byte, error) {
+	stream, err := stego.DecryptStream(key, []byte("header"), testChunkSize, bytes.NewReader(encrypted))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(stream)
+}
+
+func Test_EncryptDecryptStream(t *testing.T) {
+	key, err := stego.GenerateMasterKey()
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 5 * testChunkSize} {
+		t.Run(fmt.Sprintf("size %d", size), func(t *testing.T) {
+			message := make([]byte, size)
+			_, err := rand.Read(message)
+			require.NoError(t, err)
+
+			decrypted, err := decryptStream(key, encryptStream(t, key, message))
+			require.NoError(t, err)
+			require.Equal(t, message, decrypted)
+		})
+	}
+}
+
+func Test_DecryptStreamTampered(t *testing.T) {
+	key, err := stego.GenerateMasterKey()
+	require.NoError(t, err)
+
+	message := bytes.Repeat([]byte("test message"), 10)
+	encrypted := encryptStream(t, key, message)
+
+	// every segment is the chunk plus the 16 bytes GCM tag, after the 7 bytes nonce prefix
+	segmentSize := testChunkSize + 16
+
+	t.Run("truncated at segment boundary", func(t *testing.T) {
+		_, err := decryptStream(key, encrypted[:7+2*segmentSize])
+		require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+	})
+
+	t.Run("swapped segments", func(t *testing.T) {
+		swapped := bytes.Clone(encrypted)
+		copy(swapped[7:], encrypted[7+segmentSize:7+2*segmentSize])
+		copy(swapped[7+segmentSize:], encrypted[7:7+segmentSize])
+
+		_, err := decryptStream(key, swapped)
+		require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+	})
+
+	t.Run("wrong additional data", func(t *testing.T) {
+		stream, err := stego.DecryptStream(key, []byte("other"), testChunkSize, bytes.NewReader(encrypted))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(stream)
+		require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+	})
+}
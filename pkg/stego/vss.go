@@ -0,0 +1,274 @@
+package stego
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// The verifiable parts (Feldman VSS) split the secret over the prime field Z_q, where q is the order of the
+// subgroup generated by g in the 2048-bit MODP group of RFC 3526 (p = 2q+1, g = 2).
+// The commitments g^a_j to the coefficients of the polynomial are public: every holder can check that
+// its part f(x) lies on the polynomial (g^f(x) = Π C_j^(x^j)) without knowing the other parts.
+// The constant term is the secret along with the random MAC key, so the commitments do not leak
+// anything useful about a low entropy secret.
+
+// CommitmentsMagic are the bytes every commitments file starts with.
+const CommitmentsMagic = "STGC"
+
+const (
+	commitmentsVersion byte = 1
+	// groupMODP2048 identifies the group of RFC 3526
+	groupMODP2048 byte = 14
+	// vssElementSize is the size of the elements of the group (and of the field)
+	vssElementSize = 256
+	// MaxVerifiableSecretSize is the maximum size of a secret split into verifiable parts:
+	// the secret and the MAC key have to be smaller than q.
+	MaxVerifiableSecretSize = 255 - macKeySize
+)
+
+var (
+	vssP, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22"+
+		"514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6"+
+		"F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F"+
+		"83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C"+
+		"180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AACAA68"+
+		"FFFFFFFFFFFFFFFF", 16)
+	vssQ = new(big.Int).Rsh(vssP, 1)
+	vssG = big.NewInt(2)
+)
+
+// ErrInconsistentPart is returned when a part does not match the commitments.
+var ErrInconsistentPart = errors.New("the part is not consistent with the commitments")
+
+// Commitments are the public commitments to the polynomial of a verifiable split (see SplitVerifiable).
+type Commitments struct {
+	SetID     ShareSetID
+	Threshold byte
+	Values    []*big.Int
+}
+
+// SplitVerifiable splits the secret into verifiable parts, with a new random share set ID.
+// It returns the commitments to publish along with the parts, to verify them (see Commitments.Verify).
+func SplitVerifiable(secret []byte, parts, threshold uint8) ([]Part, *Commitments, error) {
+	if threshold < 2 || parts < threshold {
+		return nil, nil, errors.Errorf("invalid parts %d and threshold %d", parts, threshold)
+	}
+
+	if len(secret) == 0 || len(secret) > MaxVerifiableSecretSize {
+		return nil, nil, errors.Errorf("the size of the secret has to be between 1 and %d bytes, got %d",
+			MaxVerifiableSecretSize, len(secret))
+	}
+
+	setID, err := NewShareSetID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	macKey := make([]byte, macKeySize)
+	if _, err := rand.Read(macKey); err != nil {
+		return nil, nil, errors.Wrap(err, "failed generating MAC key")
+	}
+
+	keyed := append(append([]byte{}, secret...), macKey...)
+
+	coefficients := []*big.Int{new(big.Int).SetBytes(keyed)}
+	for i := 1; i < int(threshold); i++ {
+		coefficient, err := rand.Int(rand.Reader, vssQ)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed generating polynomial")
+		}
+
+		coefficients = append(coefficients, coefficient)
+	}
+
+	commitments := &Commitments{SetID: setID, Threshold: threshold}
+	for _, coefficient := range coefficients {
+		commitments.Values = append(commitments.Values, new(big.Int).Exp(vssG, coefficient, vssP))
+	}
+
+	keys := []Part{}
+	for x := 1; x <= int(parts); x++ {
+		content := binary.BigEndian.AppendUint16(nil, uint16(len(keyed)))
+		content = append(content, evaluate(coefficients, big.NewInt(int64(x))).FillBytes(make([]byte, vssElementSize))...)
+
+		part := NewPart(PartVersion4, parts, threshold, byte(x), content)
+		part.SetID = setID
+		part.MAC = part.computeMAC(keyed)
+
+		keys = append(keys, part)
+	}
+
+	return keys, commitments, nil
+}
+
+// ReshareVerifiable combines the parts recovering the secret, and splits it again into verifiable parts
+// (see Reshare).
+func ReshareVerifiable(parts []Part, newParts, newThreshold uint8) ([]Part, *Commitments, error) {
+	secret, err := Combine(parts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return SplitVerifiable(secret, newParts, newThreshold)
+}
+
+// evaluate returns the value of the polynomial in x, modulo q.
+func evaluate(coefficients []*big.Int, x *big.Int) *big.Int {
+	y := new(big.Int)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y.Mul(y, x)
+		y.Add(y, coefficients[i])
+		y.Mod(y, vssQ)
+	}
+
+	return y
+}
+
+// parseVerifiablePart returns the size of the secret and the value f(x) of the part.
+func parseVerifiablePart(part Part) (int, *big.Int, error) {
+	if part.Version != PartVersion4 || len(part.Content) != 2+vssElementSize {
+		return 0, nil, errors.New("invalid verifiable part")
+	}
+
+	y := new(big.Int).SetBytes(part.Content[2:])
+	if y.Cmp(vssQ) >= 0 {
+		return 0, nil, errors.New("invalid verifiable part: value out of range")
+	}
+
+	return int(binary.BigEndian.Uint16(part.Content)), y, nil
+}
+
+// combineVerifiable interpolates the polynomial in 0 with the parts.
+func combineVerifiable(parts []Part) ([]byte, error) {
+	size := 0
+	secret := new(big.Int)
+
+	for i, part := range parts {
+		partSize, y, err := parseVerifiablePart(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 && partSize != size {
+			return nil, errors.Errorf("parts with different secret sizes: %d, %d", size, partSize)
+		}
+
+		size = partSize
+
+		// Lagrange basis polynomial in 0: Π x_j / (x_j - x_i)
+		numerator, denominator := big.NewInt(1), big.NewInt(1)
+
+		for j, other := range parts {
+			if j == i {
+				continue
+			}
+
+			numerator.Mul(numerator, big.NewInt(int64(other.Tag)))
+			denominator.Mul(denominator, big.NewInt(int64(other.Tag)-int64(part.Tag)))
+		}
+
+		denominator.Mod(denominator, vssQ)
+		if denominator.ModInverse(denominator, vssQ) == nil {
+			return nil, errors.New("parts with duplicated tags")
+		}
+
+		term := numerator.Mul(numerator, denominator)
+		secret.Add(secret, term.Mul(term, y))
+		secret.Mod(secret, vssQ)
+	}
+
+	if secret.BitLen() > size*8 {
+		return nil, errors.New("failed combining secret: invalid parts")
+	}
+
+	return secret.FillBytes(make([]byte, size)), nil
+}
+
+// Verify checks that the part is consistent with the commitments, i.e. that it lies on the same polynomial
+// of the other parts of the share set.
+func (c *Commitments) Verify(part Part) error {
+	if part.Version != PartVersion4 {
+		return errors.Errorf("the part is not verifiable (version %c)", part.Version)
+	}
+
+	if part.SetID != c.SetID {
+		return errors.Errorf("the part belongs to the share set %s, the commitments to %s", part.SetID, c.SetID)
+	}
+
+	if part.Threshold != c.Threshold {
+		return errors.Wrapf(ErrInconsistentPart, "threshold %d, expected %d", part.Threshold, c.Threshold)
+	}
+
+	_, y, err := parseVerifiablePart(part)
+	if err != nil {
+		return err
+	}
+
+	// g^f(x) = Π C_j^(x^j)
+	x := big.NewInt(int64(part.Tag))
+	power := big.NewInt(1)
+	expected := big.NewInt(1)
+
+	for _, value := range c.Values {
+		expected.Mul(expected, new(big.Int).Exp(value, power, vssP))
+		expected.Mod(expected, vssP)
+		power.Mul(power, x)
+		power.Mod(power, vssQ)
+	}
+
+	if new(big.Int).Exp(vssG, y, vssP).Cmp(expected) != 0 {
+		return ErrInconsistentPart
+	}
+
+	return nil
+}
+
+// MarshalBinary returns the binary representation of the commitments.
+func (c *Commitments) MarshalBinary() ([]byte, error) {
+	out := append([]byte(CommitmentsMagic), commitmentsVersion, groupMODP2048)
+	out = append(out, c.SetID[:]...)
+	out = append(out, c.Threshold)
+
+	for _, value := range c.Values {
+		out = append(out, value.FillBytes(make([]byte, vssElementSize))...)
+	}
+
+	return out, nil
+}
+
+// UnmarshalCommitments parses the commitments from their binary representation.
+func UnmarshalCommitments(content []byte) (*Commitments, error) {
+	headerLen := len(CommitmentsMagic) + 2 + ShareSetIDSize + 1
+	if len(content) < headerLen || !bytes.HasPrefix(content, []byte(CommitmentsMagic)) {
+		return nil, errors.New("invalid commitments")
+	}
+
+	version, group := content[len(CommitmentsMagic)], content[len(CommitmentsMagic)+1]
+	if version != commitmentsVersion || group != groupMODP2048 {
+		return nil, errors.Errorf("unsupported commitments version %d (group %d)", version, group)
+	}
+
+	c := &Commitments{Threshold: content[headerLen-1]}
+	copy(c.SetID[:], content[len(CommitmentsMagic)+2:])
+
+	values := content[headerLen:]
+	if c.Threshold < 2 || len(values) != int(c.Threshold)*vssElementSize {
+		return nil, errors.New("invalid commitments: wrong number of values")
+	}
+
+	for len(values) > 0 {
+		value := new(big.Int).SetBytes(values[:vssElementSize])
+		if value.Sign() == 0 || value.Cmp(vssP) >= 0 {
+			return nil, errors.New("invalid commitments: value out of range")
+		}
+
+		c.Values = append(c.Values, value)
+		values = values[vssElementSize:]
+	}
+
+	return c, nil
+}
@@ -0,0 +1,84 @@
+package stego_test
+
+import (
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SplitVerifiable(t *testing.T) {
+	secret := []byte("test secret")
+
+	parts, commitments, err := stego.SplitVerifiable(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, parts, 5)
+	require.Len(t, commitments.Values, 3)
+
+	for _, part := range parts {
+		assert.Equal(t, stego.PartVersion4, part.Version)
+		assert.Equal(t, commitments.SetID, part.SetID)
+		require.NoError(t, commitments.Verify(part))
+
+		parsed, err := stego.NewPartFromContent(part.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, part, parsed)
+	}
+
+	combined, err := stego.Combine(parts[2:])
+	require.NoError(t, err)
+	assert.Equal(t, secret, combined)
+
+	_, err = stego.Combine(parts[:2])
+	require.Error(t, err)
+
+	// the commitments are published along with the encrypted file
+	marshaled, err := commitments.MarshalBinary()
+	require.NoError(t, err)
+
+	parsed, err := stego.UnmarshalCommitments(marshaled)
+	require.NoError(t, err)
+	assert.Equal(t, commitments, parsed)
+
+	_, err = stego.UnmarshalCommitments(marshaled[:len(marshaled)-1])
+	require.Error(t, err)
+
+	_, _, err = stego.SplitVerifiable(make([]byte, stego.MaxVerifiableSecretSize+1), 5, 3)
+	require.Error(t, err)
+}
+
+func Test_CommitmentsVerify(t *testing.T) {
+	parts, commitments, err := stego.SplitVerifiable([]byte("test secret"), 3, 2)
+	require.NoError(t, err)
+
+	corrupted := parts[1]
+	corrupted.Content = append([]byte{}, corrupted.Content...)
+	corrupted.Content[len(corrupted.Content)-1] ^= 1
+	require.ErrorIs(t, commitments.Verify(corrupted), stego.ErrInconsistentPart)
+
+	// a part moved to another position of the polynomial
+	moved := parts[0]
+	moved.Tag = parts[2].Tag
+	require.ErrorIs(t, commitments.Verify(moved), stego.ErrInconsistentPart)
+
+	otherParts, _, err := stego.SplitVerifiable([]byte("test secret"), 3, 2)
+	require.NoError(t, err)
+	require.Error(t, commitments.Verify(otherParts[0]))
+
+	// the parts that are not verifiable are rejected
+	plainParts, err := stego.Split([]byte("test secret"), 3, 2)
+	require.NoError(t, err)
+	require.Error(t, commitments.Verify(plainParts[0]))
+
+	// the bad parts are detected by their MAC as well
+	combined, bad, err := stego.CombineChecked([]stego.Part{parts[0], corrupted, parts[2]})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test secret"), combined)
+	assert.Equal(t, []stego.Part{corrupted}, bad)
+
+	newParts, newCommitments, err := stego.ReshareVerifiable(parts[1:], 4, 3)
+	require.NoError(t, err)
+	require.NoError(t, newCommitments.Verify(newParts[3]))
+	require.Error(t, commitments.Verify(newParts[3]))
+}
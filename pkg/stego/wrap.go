@@ -0,0 +1,66 @@
+package stego
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PartWrapped marks a part encrypted with the passphrase of its holder (see WrapPart).
+const PartWrapped byte = 'W'
+
+// ErrWrappedPart is returned when a part encrypted with the passphrase of its holder is read without it.
+var ErrWrappedPart = errors.New("the part is protected by the passphrase of its holder")
+
+// IsWrappedPart reports whether the content is a part encrypted with the passphrase of its holder.
+func IsWrappedPart(content []byte) bool {
+	return len(content) > 0 && content[0] == PartWrapped
+}
+
+// WrapPart encrypts the part with the passphrase of its holder: the key is derived with Argon2id,
+// and the part is encrypted with AES-GCM. The KDF parameters are stored in the clear before the
+// encrypted part, and they are authenticated.
+func WrapPart(part Part, passphrase []byte) ([]byte, error) {
+	params, err := NewArgon2idParams()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := DeriveKey(passphrase, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed deriving key from passphrase")
+	}
+
+	prefix := append([]byte{PartWrapped}, marshalKDFParams(params)...)
+
+	sealed, err := Seal(key, part.Bytes(), prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed encrypting part")
+	}
+
+	return append(prefix, sealed...), nil
+}
+
+// UnwrapPart decrypts a part encrypted by WrapPart. It returns ErrAuthenticationFailed if the passphrase is wrong.
+func UnwrapPart(content, passphrase []byte) (Part, error) {
+	if !IsWrappedPart(content) {
+		return Part{}, errors.New("invalid wrapped part: missing marker")
+	}
+
+	params, err := unmarshalKDFParams(content[1:])
+	if err != nil || params.KDF == KDFNone {
+		return Part{}, errors.New("invalid wrapped part: invalid KDF parameters")
+	}
+
+	prefixLen := 1 + len(marshalKDFParams(params))
+
+	key, err := DeriveKey(passphrase, params)
+	if err != nil {
+		return Part{}, errors.Wrap(err, "failed deriving key from passphrase")
+	}
+
+	plain, err := Open(key, content[prefixLen:], content[:prefixLen])
+	if err != nil {
+		return Part{}, err
+	}
+
+	return NewPartFromContent(plain)
+}
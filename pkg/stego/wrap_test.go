@@ -0,0 +1,39 @@
+package stego_test
+
+import (
+	"testing"
+
+	"github.com/enrichman/stegosecrets/pkg/stego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WrapPart(t *testing.T) {
+	parts, err := stego.Split([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+
+	wrapped, err := stego.WrapPart(parts[0], []byte("holder passphrase"))
+	require.NoError(t, err)
+	assert.True(t, stego.IsWrappedPart(wrapped))
+	assert.False(t, stego.IsWrappedPart(parts[0].Bytes()))
+
+	_, err = stego.NewPartFromContent(wrapped)
+	require.ErrorIs(t, err, stego.ErrWrappedPart)
+
+	_, err = stego.UnwrapPart(wrapped, []byte("wrong passphrase"))
+	require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+
+	part, err := stego.UnwrapPart(wrapped, []byte("holder passphrase"))
+	require.NoError(t, err)
+	assert.Equal(t, parts[0], part)
+
+	// the KDF parameters are authenticated
+	tampered := append([]byte{}, wrapped...)
+	tampered[len(tampered)-1] ^= 1
+
+	_, err = stego.UnwrapPart(tampered, []byte("holder passphrase"))
+	require.ErrorIs(t, err, stego.ErrAuthenticationFailed)
+
+	_, err = stego.UnwrapPart(wrapped[:10], []byte("holder passphrase"))
+	require.Error(t, err)
+}